@@ -0,0 +1,68 @@
+// Command slogctx-migrate rewrites slog logging calls to pass the context in
+// scope, to help convert a codebase to slogctx.
+//
+// Inside functions where a context.Context variable is in scope, it rewrites
+// calls of the slog top-level logging functions and of the logging methods of
+// slog.Logger:
+//
+//	slog.Info(msg, args...)    becomes  slogctx.Info(ctx, msg, args...)
+//	logger.Info(msg, args...)  becomes  logger.WithContext(ctx).Info(msg, args...)
+//
+// Struct fields of type *slog.Logger become *slogctx.Logger if all their uses
+// in the loaded packages are logging calls with a context in scope, or
+// assignments. Their calls then become logger.Info(ctx, msg, args...), and
+// assigned values are wrapped with slogctx.NewLogger. Fields used by packages
+// that are not loaded must not be converted, so load all of them.
+//
+// Calls without a context in scope and fields that could not be converted are
+// reported. Usage:
+//
+//	slogctx-migrate [-w] [packages]
+//
+// Without -w, slogctx-migrate lists the files it would change.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+)
+
+func main() {
+	write := flag.Bool("w", false, "write changes to the source files instead of listing them")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: slogctx-migrate [-w] [packages]\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	patterns := flag.Args()
+	if len(patterns) == 0 {
+		patterns = []string{"."}
+	}
+	res, err := migrate("", patterns...)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "slogctx-migrate: %v\n", err)
+		os.Exit(1)
+	}
+
+	for _, p := range res.problems {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", p.pos, p.msg)
+	}
+	names := make([]string, 0, len(res.files))
+	for name := range res.files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if !*write {
+			fmt.Println(name)
+			continue
+		}
+		if err := os.WriteFile(name, res.files[name], 0o644); err != nil {
+			fmt.Fprintf(os.Stderr, "slogctx-migrate: %v\n", err)
+			os.Exit(1)
+		}
+	}
+}
@@ -0,0 +1,527 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/jellevandenhooff/slogctx/analysis/internal/slogtypes"
+	"golang.org/x/tools/go/ast/astutil"
+	"golang.org/x/tools/go/ast/inspector"
+	"golang.org/x/tools/go/packages"
+)
+
+// result is the result of a migration.
+type result struct {
+	files    map[string][]byte // rewritten source of the changed files
+	problems []problem         // sorted by position
+}
+
+// problem is a site the migration could not convert.
+type problem struct {
+	pos token.Position
+	msg string
+}
+
+// edit replaces the source between pos and end with text.
+type edit struct {
+	pos, end token.Pos
+	text     string
+}
+
+// field is a struct field of type *slog.Logger that may be converted to
+// *slogctx.Logger.
+type field struct {
+	name string
+	pos  token.Pos
+	decl *fieldDecl
+
+	reason   string // why the field cannot be converted, if not empty
+	edits    []edit // edits of the uses if the field is converted
+	fallback []edit // edits of the uses if the field is not converted
+}
+
+// fieldDecl is the declaration of one or more fields sharing a type.
+type fieldDecl struct {
+	fields   []*field
+	typeEdit edit
+}
+
+// reject records that f cannot be converted, keeping the first reason.
+func (f *field) reject(format string, args ...any) {
+	if f.reason == "" {
+		f.reason = fmt.Sprintf(format, args...)
+	}
+}
+
+// migrator holds the state of a migration.
+type migrator struct {
+	fset     *token.FileSet
+	fields   map[token.Pos]*field // by position of the field name
+	decls    []*fieldDecl
+	edits    []edit
+	problems []problem
+	src      map[string][]byte // by file name
+}
+
+// logMethods are the slog.Logger methods that log using the context of the
+// logger.
+var logMethods = map[string]bool{
+	"Debug":    true,
+	"Info":     true,
+	"Warn":     true,
+	"Error":    true,
+	"Log":      true,
+	"LogAttrs": true,
+}
+
+// migrate loads the packages matching patterns in dir, including their tests,
+// and returns the rewritten files and the sites it could not convert.
+func migrate(dir string, patterns ...string) (*result, error) {
+	cfg := &packages.Config{
+		Mode:  packages.NeedName | packages.NeedFiles | packages.NeedSyntax | packages.NeedTypes | packages.NeedTypesInfo,
+		Dir:   dir,
+		Tests: true,
+	}
+	pkgs, err := packages.Load(cfg, patterns...)
+	if err != nil {
+		return nil, err
+	}
+	if packages.PrintErrors(pkgs) > 0 {
+		return nil, fmt.Errorf("packages contain errors")
+	}
+	if len(pkgs) == 0 {
+		return nil, fmt.Errorf("no packages match %s", strings.Join(patterns, " "))
+	}
+
+	m := &migrator{
+		fset:   pkgs[0].Fset,
+		fields: make(map[token.Pos]*field),
+		src:    make(map[string][]byte),
+	}
+	// Packages with tests are loaded twice, with and without their test
+	// files, sharing the syntax trees. Handle every file once, and identify
+	// fields by position instead of by object.
+	type pkgFile struct {
+		pkg  *packages.Package
+		file *ast.File
+	}
+	var files []pkgFile
+	seen := make(map[*ast.File]bool)
+	for _, pkg := range pkgs {
+		for _, file := range pkg.Syntax {
+			if !seen[file] {
+				seen[file] = true
+				files = append(files, pkgFile{pkg, file})
+			}
+		}
+	}
+	for _, f := range files {
+		m.findFields(f.pkg, f.file)
+	}
+	for _, f := range files {
+		if err := m.rewrite(f.pkg, f.file); err != nil {
+			return nil, err
+		}
+	}
+	m.resolveFields()
+	return m.result()
+}
+
+// qualifier returns the prefix for names from slogctx in file.
+func qualifier(pkg *packages.Package, file *ast.File) string {
+	if pkg.Types.Path() == slogtypes.SlogctxPath {
+		return ""
+	}
+	for _, spec := range file.Imports {
+		if p, err := strconv.Unquote(spec.Path.Value); err == nil && p == slogtypes.SlogctxPath && spec.Name != nil {
+			return spec.Name.Name + "."
+		}
+	}
+	return "slogctx."
+}
+
+// findFields records the named struct fields of type *slog.Logger declared in
+// file.
+func (m *migrator) findFields(pkg *packages.Package, file *ast.File) {
+	qual := qualifier(pkg, file)
+	ast.Inspect(file, func(n ast.Node) bool {
+		st, ok := n.(*ast.StructType)
+		if !ok {
+			return true
+		}
+		for _, f := range st.Fields.List {
+			if len(f.Names) == 0 || !slogtypes.IsLogger(pkg.TypesInfo.TypeOf(f.Type)) {
+				continue
+			}
+			decl := &fieldDecl{typeEdit: edit{f.Type.Pos(), f.Type.End(), "*" + qual + "Logger"}}
+			for _, id := range f.Names {
+				fld := &field{name: id.Name, pos: id.Pos(), decl: decl}
+				decl.fields = append(decl.fields, fld)
+				m.fields[id.Pos()] = fld
+			}
+			m.decls = append(m.decls, decl)
+		}
+		return true
+	})
+}
+
+// fieldOf returns the field selected by x, if it is one of m.fields.
+func (m *migrator) fieldOf(info *types.Info, x ast.Expr) *field {
+	sel, ok := ast.Unparen(x).(*ast.SelectorExpr)
+	if !ok {
+		return nil
+	}
+	s, ok := info.Selections[sel]
+	if !ok || s.Kind() != types.FieldVal {
+		return nil
+	}
+	return m.fields[s.Obj().Pos()]
+}
+
+// rewrite records the edits and problems of file.
+func (m *migrator) rewrite(pkg *packages.Package, file *ast.File) error {
+	info := pkg.TypesInfo
+	qual := qualifier(pkg, file)
+
+	// withContext holds the variables initialized with a call of
+	// WithContext. Their definitions precede their uses.
+	withContext := make(map[types.Object]bool)
+	// handled holds the field selectors whose use is already recorded.
+	handled := make(map[ast.Expr]bool)
+
+	var err error
+	nodeFilter := []ast.Node{
+		(*ast.AssignStmt)(nil),
+		(*ast.ValueSpec)(nil),
+		(*ast.CompositeLit)(nil),
+		(*ast.CallExpr)(nil),
+		(*ast.SelectorExpr)(nil),
+	}
+	inspector.New([]*ast.File{file}).Preorder(nodeFilter, func(n ast.Node) {
+		if err != nil {
+			return
+		}
+		switch n := n.(type) {
+		case *ast.AssignStmt:
+			if len(n.Lhs) != len(n.Rhs) {
+				return
+			}
+			for i, lhs := range n.Lhs {
+				if id, ok := lhs.(*ast.Ident); ok && n.Tok == token.DEFINE && slogtypes.IsWithContext(info, n.Rhs[i]) {
+					withContext[info.Defs[id]] = true
+				}
+				if f := m.fieldOf(info, lhs); f != nil {
+					handled[ast.Unparen(lhs)] = true
+					m.assign(info, f, n.Rhs[i], qual)
+				}
+			}
+		case *ast.ValueSpec:
+			if len(n.Names) == len(n.Values) {
+				for i, id := range n.Names {
+					if slogtypes.IsWithContext(info, n.Values[i]) {
+						withContext[info.Defs[id]] = true
+					}
+				}
+			}
+		case *ast.CompositeLit:
+			st, ok := info.TypeOf(n).Underlying().(*types.Struct)
+			if !ok {
+				return
+			}
+			for i, elt := range n.Elts {
+				var v *types.Var
+				if kv, ok := elt.(*ast.KeyValueExpr); ok {
+					id, _ := kv.Key.(*ast.Ident)
+					if id != nil {
+						v, _ = info.Uses[id].(*types.Var)
+					}
+					elt = kv.Value
+				} else {
+					v = st.Field(i)
+				}
+				if v == nil {
+					continue
+				}
+				if f := m.fields[v.Pos()]; f != nil {
+					m.assign(info, f, elt, qual)
+				}
+			}
+		case *ast.CallExpr:
+			err = m.call(pkg, n, qual, withContext, handled)
+		case *ast.SelectorExpr:
+			if f := m.fieldOf(info, n); f != nil && !handled[n] {
+				f.reject("use at %s is not a logging call or an assignment", m.shortPos(n.Pos()))
+			}
+		}
+	})
+	return err
+}
+
+// assign records the assignment of x to f.
+func (m *migrator) assign(info *types.Info, f *field, x ast.Expr, qual string) {
+	if info.Types[x].IsNil() {
+		return
+	}
+	if g := m.fieldOf(info, x); g != nil {
+		// Assigning one field to another keeps both types in sync.
+		g.reject("assigned to field %s at %s", f.name, m.shortPos(x.Pos()))
+		f.reject("assigned from field %s at %s", g.name, m.shortPos(x.Pos()))
+		return
+	}
+	f.edits = append(f.edits,
+		edit{x.Pos(), x.Pos(), qual + "NewLogger("},
+		edit{x.End(), x.End(), ")"},
+	)
+}
+
+// call records the edits and problems of a logging call.
+func (m *migrator) call(pkg *packages.Package, call *ast.CallExpr, qual string, withContext map[types.Object]bool, handled map[ast.Expr]bool) error {
+	info := pkg.TypesInfo
+	sel, ok := call.Fun.(*ast.SelectorExpr)
+	if !ok {
+		return nil
+	}
+	fn, ok := info.Uses[sel.Sel].(*types.Func)
+	if !ok || fn.Pkg() == nil || fn.Pkg().Path() != slogtypes.SlogPath {
+		return nil
+	}
+	recv := fn.Type().(*types.Signature).Recv()
+	ctx := slogtypes.ContextInScope(pkg.Types, call.Pos())
+
+	switch {
+	case recv == nil && slogtypes.LogFuncs[fn.Name()]:
+		if ctx == nil {
+			m.report(call.Pos(), "slog.%s call without context in scope", fn.Name())
+			return nil
+		}
+		// Replace the qualifier including its dot, which qual holds if
+		// needed.
+		m.edits = append(m.edits,
+			edit{sel.X.Pos(), sel.Sel.Pos(), qual},
+			ctxArg(call, ctx.Name()),
+		)
+
+	case recv != nil && logMethods[fn.Name()] && slogtypes.IsWithContext(info, sel.X):
+		// x.WithContext(c).Info(...) becomes x.Info(c, ...) if x is a
+		// converted field.
+		wc := ast.Unparen(sel.X).(*ast.CallExpr)
+		wcSel := wc.Fun.(*ast.SelectorExpr)
+		f := m.fieldOf(info, wcSel.X)
+		if f == nil {
+			return nil
+		}
+		handled[ast.Unparen(wcSel.X)] = true
+		if !slogtypes.LogFuncs[fn.Name()] || len(wc.Args) != 1 {
+			f.reject("%s call at %s has no slogctx equivalent", fn.Name(), m.shortPos(call.Pos()))
+			return nil
+		}
+		arg, err := m.source(wc.Args[0])
+		if err != nil {
+			return err
+		}
+		f.edits = append(f.edits,
+			edit{wcSel.X.End(), wc.End(), ""},
+			ctxArg(call, arg),
+		)
+
+	case recv != nil && logMethods[fn.Name()]:
+		if id, ok := ast.Unparen(sel.X).(*ast.Ident); ok && withContext[info.Uses[id]] {
+			return nil
+		}
+		f := m.fieldOf(info, sel.X)
+		if f != nil {
+			handled[ast.Unparen(sel.X)] = true
+		}
+		if ctx == nil {
+			m.report(call.Pos(), "%s call without context in scope", fn.Name())
+			if f != nil {
+				f.reject("%s call at %s has no context in scope", fn.Name(), m.shortPos(call.Pos()))
+			}
+			return nil
+		}
+		withCtx := edit{sel.X.End(), sel.X.End(), ".WithContext(" + ctx.Name() + ")"}
+		if f == nil {
+			m.edits = append(m.edits, withCtx)
+			return nil
+		}
+		f.fallback = append(f.fallback, withCtx)
+		if !slogtypes.LogFuncs[fn.Name()] {
+			f.reject("%s call at %s has no slogctx equivalent", fn.Name(), m.shortPos(call.Pos()))
+			return nil
+		}
+		f.edits = append(f.edits, ctxArg(call, ctx.Name()))
+	}
+	return nil
+}
+
+// ctxArg returns the edit passing ctx as first argument of call.
+func ctxArg(call *ast.CallExpr, ctx string) edit {
+	if len(call.Args) > 0 {
+		ctx += ", "
+	}
+	return edit{call.Lparen + 1, call.Lparen + 1, ctx}
+}
+
+// resolveFields records the edits of the fields, converting the fields that
+// can be converted together with the fields sharing their declaration.
+func (m *migrator) resolveFields() {
+	for _, decl := range m.decls {
+		var reason string
+		for _, f := range decl.fields {
+			if f.reason != "" {
+				reason = fmt.Sprintf("field %s not converted to *slogctx.Logger: %s", f.name, f.reason)
+				break
+			}
+		}
+		if reason == "" {
+			m.edits = append(m.edits, decl.typeEdit)
+			for _, f := range decl.fields {
+				m.edits = append(m.edits, f.edits...)
+			}
+			continue
+		}
+		for _, f := range decl.fields {
+			if f.reason == "" {
+				m.report(f.pos, "field %s not converted to *slogctx.Logger: declared with field that is not converted", f.name)
+			} else {
+				m.report(f.pos, "field %s not converted to *slogctx.Logger: %s", f.name, f.reason)
+			}
+			m.edits = append(m.edits, f.fallback...)
+		}
+	}
+}
+
+// result applies the edits and returns the result of the migration.
+func (m *migrator) result() (*result, error) {
+	res := &result{files: make(map[string][]byte)}
+
+	byFile := make(map[string][]edit)
+	for _, e := range m.edits {
+		name := m.fset.Position(e.pos).Filename
+		byFile[name] = append(byFile[name], e)
+	}
+	for name, edits := range byFile {
+		src, err := m.apply(name, edits)
+		if err != nil {
+			return nil, err
+		}
+		res.files[name] = src
+	}
+
+	sort.SliceStable(m.problems, func(i, j int) bool {
+		pi, pj := m.problems[i].pos, m.problems[j].pos
+		if pi.Filename != pj.Filename {
+			return pi.Filename < pj.Filename
+		}
+		return pi.Offset < pj.Offset
+	})
+	res.problems = m.problems
+	return res, nil
+}
+
+// apply applies edits to the file name, fixes its imports, and formats it.
+func (m *migrator) apply(name string, edits []edit) ([]byte, error) {
+	src, err := m.file(name)
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(edits, func(i, j int) bool { return edits[i].pos < edits[j].pos })
+
+	tf := m.fset.File(edits[0].pos)
+	var buf bytes.Buffer
+	last := 0
+	for _, e := range edits {
+		start, end := tf.Offset(e.pos), tf.Offset(e.end)
+		if start < last {
+			return nil, fmt.Errorf("%s: overlapping edits", m.fset.Position(e.pos))
+		}
+		buf.Write(src[last:start])
+		buf.WriteString(e.text)
+		last = end
+	}
+	buf.Write(src[last:])
+
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, name, buf.Bytes(), parser.ParseComments)
+	if err != nil {
+		return nil, fmt.Errorf("parsing rewritten file: %v", err)
+	}
+	if file.Name.Name != "slogctx" && usesName(file, "slogctx") {
+		astutil.AddImport(fset, file, slogtypes.SlogctxPath)
+	}
+	if !astutil.UsesImport(file, slogtypes.SlogPath) {
+		astutil.DeleteImport(fset, file, slogtypes.SlogPath)
+	}
+	buf.Reset()
+	if err := format.Node(&buf, fset, file); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// usesName reports whether file refers to a package with the given name that
+// it does not import.
+func usesName(file *ast.File, name string) bool {
+	for _, spec := range file.Imports {
+		if spec.Name != nil && spec.Name.Name == name {
+			return false
+		}
+		if p, err := strconv.Unquote(spec.Path.Value); err == nil && p == slogtypes.SlogctxPath {
+			return false
+		}
+	}
+	used := false
+	ast.Inspect(file, func(n ast.Node) bool {
+		if sel, ok := n.(*ast.SelectorExpr); ok {
+			if id, ok := sel.X.(*ast.Ident); ok && id.Name == name && id.Obj == nil {
+				used = true
+			}
+		}
+		return !used
+	})
+	return used
+}
+
+// file returns the contents of the file name.
+func (m *migrator) file(name string) ([]byte, error) {
+	if src, ok := m.src[name]; ok {
+		return src, nil
+	}
+	src, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	m.src[name] = src
+	return src, nil
+}
+
+// source returns the source text of x.
+func (m *migrator) source(x ast.Expr) (string, error) {
+	start, end := m.fset.Position(x.Pos()), m.fset.Position(x.End())
+	src, err := m.file(start.Filename)
+	if err != nil {
+		return "", err
+	}
+	return string(src[start.Offset:end.Offset]), nil
+}
+
+// report records a problem at pos.
+func (m *migrator) report(pos token.Pos, format string, args ...any) {
+	m.problems = append(m.problems, problem{m.fset.Position(pos), fmt.Sprintf(format, args...)})
+}
+
+// shortPos formats pos with the base name of its file.
+func (m *migrator) shortPos(pos token.Pos) string {
+	p := m.fset.Position(pos)
+	return fmt.Sprintf("%s:%d", filepath.Base(p.Filename), p.Line)
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "update golden files in testdata")
+
+func TestMigrate(t *testing.T) {
+	res, err := migrate("testdata", "./a")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	names, err := filepath.Glob("testdata/a/*.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range names {
+		abs, err := filepath.Abs(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, ok := res.files[abs]
+		if !ok {
+			t.Errorf("%s not changed", name)
+			continue
+		}
+		golden := name + ".golden"
+		if *update {
+			if err := os.WriteFile(golden, got, 0o644); err != nil {
+				t.Fatal(err)
+			}
+			continue
+		}
+		want, err := os.ReadFile(golden)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != string(want) {
+			t.Errorf("%s:\ngot:\n%s\nwant:\n%s", name, got, want)
+		}
+	}
+
+	var problems []string
+	for _, p := range res.problems {
+		problems = append(problems, fmt.Sprintf("%s:%d: %s", filepath.Base(p.pos.Filename), p.pos.Line, p.msg))
+	}
+	want := []string{
+		"a.go:11: field other not converted to *slogctx.Logger: Info call at a.go:37 has no context in scope",
+		"a.go:36: slog.Info call without context in scope",
+		"a.go:37: Info call without context in scope",
+	}
+	if got, want := strings.Join(problems, "\n"), strings.Join(want, "\n"); got != want {
+		t.Errorf("problems:\ngot:\n%s\nwant:\n%s", got, want)
+	}
+}
@@ -0,0 +1,38 @@
+package a
+
+import (
+	"context"
+
+	"golang.org/x/exp/slog"
+)
+
+type server struct {
+	logger *slog.Logger
+	other  *slog.Logger
+}
+
+func newServer(logger *slog.Logger) *server {
+	return &server{logger: logger, other: logger}
+}
+
+func (s *server) handle(ctx context.Context, err error) {
+	slog.Info("handling", "path", "/")
+	s.logger.Info("handled")
+	s.logger.WithContext(ctx).Warn("slow")
+	s.logger.Error("failed", err)
+	s.other.Debug("debug")
+	logger := slog.Default()
+	logger.Log(slog.LevelInfo, "log")
+	scoped := logger.WithContext(ctx)
+	scoped.Info("scoped")
+}
+
+func (s *server) reset(logger *slog.Logger) {
+	s.logger = logger
+	s.other = nil
+}
+
+func (s *server) background() {
+	slog.Info("no context")
+	s.other.Info("no context")
+}
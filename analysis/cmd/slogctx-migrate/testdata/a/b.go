@@ -0,0 +1,11 @@
+package a
+
+import (
+	"context"
+
+	"golang.org/x/exp/slog"
+)
+
+func run(ctx context.Context) {
+	slog.Warn("running")
+}
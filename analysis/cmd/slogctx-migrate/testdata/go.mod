@@ -0,0 +1,5 @@
+module example.com/testdata
+
+go 1.19
+
+require golang.org/x/exp v0.0.0-20230129154200-a960b3787bd2
@@ -0,0 +1,26 @@
+// Command slogctxvet runs the slogctx analyzers.
+//
+// It reports slog logging calls that do not pass the context in scope, and
+// malformed key-value arguments of slog and slogctx calls. Run it
+// directly, optionally applying the suggested fixes:
+//
+//	slogctxvet ./...
+//	slogctxvet -fix ./...
+//
+// or as a vet tool:
+//
+//	go vet -vettool=$(which slogctxvet) ./...
+package main
+
+import (
+	"github.com/jellevandenhooff/slogctx/analysis/slogargs"
+	"github.com/jellevandenhooff/slogctx/analysis/slogcontext"
+	"golang.org/x/tools/go/analysis/multichecker"
+)
+
+func main() {
+	multichecker.Main(
+		slogargs.Analyzer,
+		slogcontext.Analyzer,
+	)
+}
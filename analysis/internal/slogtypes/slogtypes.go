@@ -0,0 +1,95 @@
+// Package slogtypes provides helpers for the slogctx analyzers and tools to
+// recognize slog and slogctx code.
+package slogtypes
+
+import (
+	"go/ast"
+	"go/token"
+	"go/types"
+)
+
+// Import paths of slog and slogctx.
+const (
+	SlogPath    = "golang.org/x/exp/slog"
+	SlogctxPath = "github.com/jellevandenhooff/slogctx"
+)
+
+// LogFuncs are the names of the slog top-level functions and slog.Logger
+// methods with a slogctx equivalent taking a context as first argument.
+var LogFuncs = map[string]bool{
+	"Debug": true,
+	"Info":  true,
+	"Warn":  true,
+	"Error": true,
+	"Log":   true,
+}
+
+// Callee returns the function or method called by call, or nil.
+func Callee(info *types.Info, call *ast.CallExpr) *types.Func {
+	var id *ast.Ident
+	switch fun := ast.Unparen(call.Fun).(type) {
+	case *ast.Ident:
+		id = fun
+	case *ast.SelectorExpr:
+		id = fun.Sel
+	default:
+		return nil
+	}
+	fn, _ := info.Uses[id].(*types.Func)
+	return fn
+}
+
+// IsFunc reports whether fn is a function or method named name in the package
+// with the given path.
+func IsFunc(fn *types.Func, path, name string) bool {
+	return fn != nil && fn.Pkg() != nil && fn.Pkg().Path() == path && fn.Name() == name
+}
+
+// IsWithContext reports whether x is a call of slog.Logger.WithContext.
+func IsWithContext(info *types.Info, x ast.Expr) bool {
+	call, ok := ast.Unparen(x).(*ast.CallExpr)
+	return ok && IsFunc(Callee(info, call), SlogPath, "WithContext")
+}
+
+// IsContext reports whether t is context.Context.
+func IsContext(t types.Type) bool {
+	return isNamed(t, "context", "Context")
+}
+
+// IsLogger reports whether t is *slog.Logger.
+func IsLogger(t types.Type) bool {
+	ptr, ok := t.(*types.Pointer)
+	return ok && isNamed(ptr.Elem(), SlogPath, "Logger")
+}
+
+func isNamed(t types.Type, path, name string) bool {
+	named, ok := t.(*types.Named)
+	if !ok {
+		return false
+	}
+	obj := named.Obj()
+	return obj.Pkg() != nil && obj.Pkg().Path() == path && obj.Name() == name
+}
+
+// ContextInScope returns the innermost variable of type context.Context
+// declared before pos in a scope of pkg enclosing pos, preferring variables
+// named ctx, or nil if there is none.
+func ContextInScope(pkg *types.Package, pos token.Pos) *types.Var {
+	pkgScope := pkg.Scope()
+	for scope := pkgScope.Innermost(pos); scope != nil && scope != pkgScope; scope = scope.Parent() {
+		var found *types.Var
+		for _, name := range scope.Names() {
+			v, ok := scope.Lookup(name).(*types.Var)
+			if !ok || name == "_" || v.Pos() >= pos || !IsContext(v.Type()) {
+				continue
+			}
+			if found == nil || name == "ctx" {
+				found = v
+			}
+		}
+		if found != nil {
+			return found
+		}
+	}
+	return nil
+}
@@ -0,0 +1,183 @@
+// Package slogargs defines an Analyzer that checks the key-value arguments of
+// slog and slogctx calls.
+//
+// # Analyzer slogargs
+//
+// slogargs: check key-value arguments of slog and slogctx calls
+//
+// Functions like slogctx.WithAttrs, slogctx.Info, and slogctx.Logger.With take
+// a list of alternating keys and values, or slog.Attrs, as final arguments.
+// Mistakes in the list are not detected at compile time; instead the logs
+// contain attributes with the key "!BADKEY". The analyzer reports:
+//
+//   - a key without a value, as in slogctx.Info(ctx, "msg", "a", 1, "b")
+//   - a key that is not a string or slog.Attr, as in slogctx.Info(ctx, "msg", 1, 2),
+//     including keys of named string types
+//   - a key that is not a constant, as in slogctx.Info(ctx, "msg", key, 1)
+//   - a key used twice in the same call
+package slogargs
+
+import (
+	"go/ast"
+	"go/constant"
+	"go/types"
+
+	"github.com/jellevandenhooff/slogctx/analysis/internal/slogtypes"
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/analysis/passes/inspect"
+	"golang.org/x/tools/go/ast/inspector"
+)
+
+const doc = `check key-value arguments of slog and slogctx calls
+
+The analyzer checks the final ...any arguments of slog and slogctx functions
+and methods, like slogctx.WithAttrs, slogctx.Info, and Logger.With, for keys
+without values, keys that are not constant strings or slog.Attrs, and
+duplicate keys.`
+
+// Analyzer checks key-value arguments of slog and slogctx calls.
+var Analyzer = &analysis.Analyzer{
+	Name:     "slogargs",
+	Doc:      doc,
+	Requires: []*analysis.Analyzer{inspect.Analyzer},
+	Run:      run,
+}
+
+// attrFuncs are the slog functions returning a slog.Attr with the key passed
+// as first argument.
+var attrFuncs = map[string]bool{
+	"String":   true,
+	"Int64":    true,
+	"Int":      true,
+	"Uint64":   true,
+	"Float64":  true,
+	"Bool":     true,
+	"Time":     true,
+	"Duration": true,
+	"Group":    true,
+	"Any":      true,
+}
+
+func run(pass *analysis.Pass) (interface{}, error) {
+	inspect := pass.ResultOf[inspect.Analyzer].(*inspector.Inspector)
+
+	nodeFilter := []ast.Node{
+		(*ast.CallExpr)(nil),
+	}
+	inspect.Preorder(nodeFilter, func(n ast.Node) {
+		call := n.(*ast.CallExpr)
+		if call.Ellipsis.IsValid() {
+			return
+		}
+		fn := slogtypes.Callee(pass.TypesInfo, call)
+		if fn == nil || fn.Pkg() == nil || (fn.Pkg().Path() != slogtypes.SlogPath && fn.Pkg().Path() != slogtypes.SlogctxPath) {
+			return
+		}
+		sig := fn.Type().(*types.Signature)
+		if !sig.Variadic() {
+			return
+		}
+		last := sig.Params().At(sig.Params().Len() - 1).Type().(*types.Slice)
+		if !isAny(last.Elem()) {
+			return
+		}
+		first := sig.Params().Len() - 1
+		if len(call.Args) <= first {
+			return
+		}
+		checkArgs(pass, fn, call.Args[first:])
+	})
+	return nil, nil
+}
+
+// checkArgs checks the key-value arguments args of a call of fn.
+func checkArgs(pass *analysis.Pass, fn *types.Func, args []ast.Expr) {
+	seen := make(map[string]bool)
+	checkKey := func(arg ast.Expr, key string) {
+		if seen[key] {
+			pass.ReportRangef(arg, "duplicate key %q in call to %s", key, fn.Name())
+		}
+		seen[key] = true
+	}
+
+	for len(args) > 0 {
+		arg := args[0]
+		t := pass.TypesInfo.TypeOf(arg)
+		if isAttr(t) {
+			if key, ok := attrKey(pass, arg); ok {
+				checkKey(arg, key)
+			}
+			args = args[1:]
+			continue
+		}
+
+		switch {
+		case t != nil && isString(t):
+			tv := pass.TypesInfo.Types[arg]
+			if tv.Value == nil {
+				pass.ReportRangef(arg, "key %s in call to %s is not a constant", types.ExprString(arg), fn.Name())
+			} else {
+				checkKey(arg, constant.StringVal(tv.Value))
+			}
+		case t != nil && (types.IsInterface(t) || isNamedString(t)):
+			pass.ReportRangef(arg, "key %s in call to %s has type %s, not string or slog.Attr", types.ExprString(arg), fn.Name(), t)
+		default:
+			// slog logs a key of any other type as a value with a missing
+			// key, and takes the next argument as the next key.
+			pass.ReportRangef(arg, "%s in call to %s is not a string key or slog.Attr", types.ExprString(arg), fn.Name())
+			args = args[1:]
+			continue
+		}
+		if len(args) == 1 {
+			pass.ReportRangef(arg, "key %s in call to %s has no value", types.ExprString(arg), fn.Name())
+			return
+		}
+		args = args[2:]
+	}
+}
+
+// attrKey returns the key of arg if arg is a call of a slog function creating
+// an Attr, like slog.String, with a constant key.
+func attrKey(pass *analysis.Pass, arg ast.Expr) (string, bool) {
+	call, ok := ast.Unparen(arg).(*ast.CallExpr)
+	if !ok || len(call.Args) == 0 {
+		return "", false
+	}
+	fn := slogtypes.Callee(pass.TypesInfo, call)
+	if fn == nil || fn.Pkg() == nil || fn.Pkg().Path() != slogtypes.SlogPath || !attrFuncs[fn.Name()] {
+		return "", false
+	}
+	tv := pass.TypesInfo.Types[call.Args[0]]
+	if tv.Value == nil || tv.Value.Kind() != constant.String {
+		return "", false
+	}
+	return constant.StringVal(tv.Value), true
+}
+
+// isAttr reports whether t is slog.Attr.
+func isAttr(t types.Type) bool {
+	named, ok := t.(*types.Named)
+	if !ok {
+		return false
+	}
+	obj := named.Obj()
+	return obj.Pkg() != nil && obj.Pkg().Path() == slogtypes.SlogPath && obj.Name() == "Attr"
+}
+
+// isString reports whether t is the string type, or the type of an untyped
+// string constant. Keys of other string types are logged as !BADKEY.
+func isString(t types.Type) bool {
+	return types.Identical(t, types.Typ[types.String]) || types.Identical(t, types.Typ[types.UntypedString])
+}
+
+// isNamedString reports whether t is a string type other than string.
+func isNamedString(t types.Type) bool {
+	basic, ok := t.Underlying().(*types.Basic)
+	return ok && basic.Info()&types.IsString != 0 && !isString(t)
+}
+
+// isAny reports whether t is the empty interface.
+func isAny(t types.Type) bool {
+	iface, ok := t.Underlying().(*types.Interface)
+	return ok && iface.Empty()
+}
@@ -0,0 +1,13 @@
+package slogargs_test
+
+import (
+	"testing"
+
+	"github.com/jellevandenhooff/slogctx/analysis/slogargs"
+	"golang.org/x/tools/go/analysis/analysistest"
+)
+
+func TestAnalyzer(t *testing.T) {
+	testdata := analysistest.TestData()
+	analysistest.Run(t, testdata, slogargs.Analyzer, "a")
+}
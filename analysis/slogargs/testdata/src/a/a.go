@@ -0,0 +1,35 @@
+package a
+
+import (
+	"context"
+
+	"github.com/jellevandenhooff/slogctx"
+	"golang.org/x/exp/slog"
+)
+
+type myString string
+
+const constKey = "const"
+
+func calls(ctx context.Context, logger *slogctx.Logger, slogger *slog.Logger, key string, anyKey any, args []any) {
+	slogctx.Info(ctx, "ok", "a", 1, slog.Int("b", 2), "c", "d", constKey, 3)
+	slogctx.Info(ctx, "msg", myString("e"), 4)                               // want `key myString\("e"\) in call to Info has type a.myString, not string or slog.Attr`
+	slogctx.WithAttrs(ctx, "a", 1, "b")                                      // want `key "b" in call to WithAttrs has no value`
+	slogctx.WithAttrs(ctx, 1, 2)                                             // want `1 in call to WithAttrs is not a string key or slog.Attr` `2 in call to WithAttrs is not a string key or slog.Attr`
+	slogctx.Info(ctx, "m", 1, "a", 2)                                        // want `1 in call to Info is not a string key or slog.Attr`
+	slogctx.Info(ctx, "m", "a", 2, 3)                                        // want `3 in call to Info is not a string key or slog.Attr`
+	slogctx.WithAttrs(ctx, key, 2)                                           // want `key key in call to WithAttrs is not a constant`
+	slogctx.WithAttrs(ctx, anyKey, 2)                                        // want `key anyKey in call to WithAttrs has type any, not string or slog.Attr`
+	logger.With("a", 1, "a", 2)                                              // want `duplicate key "a" in call to With`
+	logger.Info(ctx, "msg", slog.String("a", "x"), constKey, 1, "const", 2)  // want `duplicate key "const" in call to Info`
+	logger.Error(ctx, "msg", nil, "a", 1, slog.Group("a", slog.Int("b", 1))) // want `duplicate key "a" in call to Error`
+	slogger.With("x")                                                        // want `key "x" in call to With has no value`
+	slog.Info("msg", "a", 1, 2, 3)                                           // want `2 in call to Info is not a string key or slog.Attr` `3 in call to Info is not a string key or slog.Attr`
+
+	// Forwarded arguments are not checked.
+	slogctx.WithAttrs(ctx, args...)
+	slog.Info("msg", args...)
+
+	// Keys with non-constant values of slog.Attr are not checked for duplicates.
+	slogctx.Info(ctx, "ok", slog.Any(key, 1), slog.Any(key, 2))
+}
@@ -0,0 +1,13 @@
+// Package slogctx is a stub of github.com/jellevandenhooff/slogctx for tests.
+package slogctx
+
+import "context"
+
+type Logger struct{}
+
+func (l *Logger) With(args ...any) *Logger                                      { return l }
+func (l *Logger) Info(ctx context.Context, msg string, args ...any)             {}
+func (l *Logger) Error(ctx context.Context, msg string, err error, args ...any) {}
+
+func WithAttrs(ctx context.Context, args ...any) context.Context { return ctx }
+func Info(ctx context.Context, msg string, args ...any)          {}
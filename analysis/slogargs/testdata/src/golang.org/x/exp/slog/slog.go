@@ -0,0 +1,16 @@
+// Package slog is a stub of golang.org/x/exp/slog for tests.
+package slog
+
+type Attr struct{}
+
+func String(key, value string) Attr     { return Attr{} }
+func Int(key string, value int) Attr    { return Attr{} }
+func Any(key string, value any) Attr    { return Attr{} }
+func Group(key string, as ...Attr) Attr { return Attr{} }
+
+type Logger struct{}
+
+func (l *Logger) With(args ...any) *Logger     { return l }
+func (l *Logger) Info(msg string, args ...any) {}
+
+func Info(msg string, args ...any) {}
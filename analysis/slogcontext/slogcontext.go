@@ -0,0 +1,225 @@
+// Package slogcontext defines an Analyzer that reports slog logging calls
+// that do not pass a context while one is in scope.
+//
+// # Analyzer slogcontext
+//
+// slogcontext: check that slog calls pass the context in scope
+//
+// Attributes added to a context with slogctx.WithAttrs, and the level set
+// with slogctx.WithMinimumLevel, only apply to logging calls that pass the
+// context. The analyzer reports calls of the slog top-level logging functions
+// and the logging methods of slog.Logger inside a function where a
+// context.Context variable is in scope, unless the logger is obtained with
+// WithContext, directly or through a variable initialized with it. For
+// example, in
+//
+//	func handle(ctx context.Context) {
+//		slog.Info("handling")
+//		logger.Warn("slow")
+//	}
+//
+// it suggests slogctx.Info(ctx, "handling") and
+// logger.WithContext(ctx).Warn("slow").
+package slogcontext
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/token"
+	"go/types"
+	"strconv"
+
+	"github.com/jellevandenhooff/slogctx/analysis/internal/slogtypes"
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/analysis/passes/inspect"
+	"golang.org/x/tools/go/ast/inspector"
+)
+
+const doc = `check that slog calls pass the context in scope
+
+The analyzer reports calls of the slog top-level logging functions and the
+logging methods of slog.Logger inside a function where a context.Context
+variable is in scope, unless the logger is obtained with WithContext. It
+suggests the slogctx equivalent.`
+
+// Analyzer reports slog logging calls without a context while one is in scope.
+var Analyzer = &analysis.Analyzer{
+	Name:     "slogcontext",
+	Doc:      doc,
+	Requires: []*analysis.Analyzer{inspect.Analyzer},
+	Run:      run,
+}
+
+// logMethods are the slog.Logger methods that log using the context of the
+// logger.
+var logMethods = map[string]bool{
+	"Debug":    true,
+	"Info":     true,
+	"Warn":     true,
+	"Error":    true,
+	"Log":      true,
+	"LogAttrs": true,
+}
+
+func run(pass *analysis.Pass) (interface{}, error) {
+	inspect := pass.ResultOf[inspect.Analyzer].(*inspector.Inspector)
+
+	nodeFilter := []ast.Node{
+		(*ast.File)(nil),
+		(*ast.AssignStmt)(nil),
+		(*ast.ValueSpec)(nil),
+		(*ast.CallExpr)(nil),
+	}
+	var file *ast.File
+	// withContext holds the variables initialized with a call of
+	// WithContext. Their definitions precede their uses.
+	withContext := make(map[types.Object]bool)
+	inspect.Preorder(nodeFilter, func(n ast.Node) {
+		var call *ast.CallExpr
+		switch n := n.(type) {
+		case *ast.File:
+			file = n
+			return
+		case *ast.AssignStmt:
+			if n.Tok == token.DEFINE && len(n.Lhs) == len(n.Rhs) {
+				for i, lhs := range n.Lhs {
+					if id, ok := lhs.(*ast.Ident); ok && slogtypes.IsWithContext(pass.TypesInfo, n.Rhs[i]) {
+						withContext[pass.TypesInfo.Defs[id]] = true
+					}
+				}
+			}
+			return
+		case *ast.ValueSpec:
+			if len(n.Names) == len(n.Values) {
+				for i, id := range n.Names {
+					if slogtypes.IsWithContext(pass.TypesInfo, n.Values[i]) {
+						withContext[pass.TypesInfo.Defs[id]] = true
+					}
+				}
+			}
+			return
+		case *ast.CallExpr:
+			call = n
+		}
+		sel, ok := call.Fun.(*ast.SelectorExpr)
+		if !ok {
+			return
+		}
+		fn, ok := pass.TypesInfo.Uses[sel.Sel].(*types.Func)
+		if !ok || fn.Pkg() == nil || fn.Pkg().Path() != slogtypes.SlogPath {
+			return
+		}
+
+		recv := fn.Type().(*types.Signature).Recv()
+		switch {
+		case recv == nil && slogtypes.LogFuncs[fn.Name()]:
+		case recv != nil && logMethods[fn.Name()] && !slogtypes.IsWithContext(pass.TypesInfo, sel.X) && !isWithContextVar(pass, withContext, sel.X):
+		default:
+			return
+		}
+
+		ctx := slogtypes.ContextInScope(pass.Pkg, call.Pos())
+		if ctx == nil {
+			return
+		}
+
+		if recv == nil {
+			pass.Report(analysis.Diagnostic{
+				Pos:     call.Pos(),
+				End:     call.End(),
+				Message: fmt.Sprintf("slog.%s call without context while %s is in scope; use slogctx.%s(%s, ...)", fn.Name(), ctx.Name(), fn.Name(), ctx.Name()),
+				SuggestedFixes: []analysis.SuggestedFix{{
+					Message:   fmt.Sprintf("Replace with slogctx.%s(%s, ...)", fn.Name(), ctx.Name()),
+					TextEdits: funcFix(pass, file, call, sel, ctx),
+				}},
+			})
+			return
+		}
+		pass.Report(analysis.Diagnostic{
+			Pos:     call.Pos(),
+			End:     call.End(),
+			Message: fmt.Sprintf("%s call without context while %s is in scope; use WithContext(%s)", fn.Name(), ctx.Name(), ctx.Name()),
+			SuggestedFixes: []analysis.SuggestedFix{{
+				Message: fmt.Sprintf("Call WithContext(%s)", ctx.Name()),
+				TextEdits: []analysis.TextEdit{{
+					Pos:     sel.X.End(),
+					End:     sel.X.End(),
+					NewText: []byte(".WithContext(" + ctx.Name() + ")"),
+				}},
+			}},
+		})
+	})
+	return nil, nil
+}
+
+// isWithContextVar reports whether x is a variable in withContext.
+func isWithContextVar(pass *analysis.Pass, withContext map[types.Object]bool, x ast.Expr) bool {
+	id, ok := ast.Unparen(x).(*ast.Ident)
+	return ok && withContext[pass.TypesInfo.Uses[id]]
+}
+
+// funcFix returns the edits replacing the call of a slog top-level function
+// with a call of its slogctx equivalent, importing slogctx if needed.
+func funcFix(pass *analysis.Pass, file *ast.File, call *ast.CallExpr, sel *ast.SelectorExpr, ctx *types.Var) []analysis.TextEdit {
+	name, importEdits := importName(pass, file, slogtypes.SlogctxPath, "slogctx")
+	args := ctx.Name()
+	if len(call.Args) > 0 {
+		args += ", "
+	}
+	edits := []analysis.TextEdit{
+		{Pos: sel.X.Pos(), End: sel.X.End(), NewText: []byte(name)},
+		{Pos: call.Lparen + 1, End: call.Lparen + 1, NewText: []byte(args)},
+	}
+	return append(edits, importEdits...)
+}
+
+// importName returns the name under which file imports path, and if file does
+// not import path, the edits adding an import with the given name.
+func importName(pass *analysis.Pass, file *ast.File, path, name string) (string, []analysis.TextEdit) {
+	for _, spec := range file.Imports {
+		p, err := strconv.Unquote(spec.Path.Value)
+		if err != nil || p != path {
+			continue
+		}
+		if spec.Name != nil {
+			return spec.Name.Name, nil
+		}
+		return name, nil
+	}
+
+	// Add the import after the last import, or after the package clause.
+	if len(file.Imports) == 0 {
+		return name, []analysis.TextEdit{{
+			Pos:     file.Name.End(),
+			End:     file.Name.End(),
+			NewText: []byte("\n\nimport " + strconv.Quote(path)),
+		}}
+	}
+	last := file.Imports[len(file.Imports)-1]
+	for _, decl := range file.Decls {
+		gen, ok := decl.(*ast.GenDecl)
+		if !ok || gen.Tok != token.IMPORT || gen.Pos() > last.Pos() || gen.End() < last.End() {
+			continue
+		}
+		if gen.Lparen.IsValid() {
+			return name, []analysis.TextEdit{{
+				Pos:     last.End(),
+				End:     last.End(),
+				NewText: []byte("\n\t" + strconv.Quote(path)),
+			}}
+		}
+		// Turn a single import into an import block.
+		var buf bytes.Buffer
+		if err := format.Node(&buf, pass.Fset, last); err != nil {
+			break
+		}
+		return name, []analysis.TextEdit{{
+			Pos:     gen.Pos(),
+			End:     gen.End(),
+			NewText: []byte("import (\n\t" + buf.String() + "\n\t" + strconv.Quote(path) + "\n)"),
+		}}
+	}
+	return name, nil
+}
@@ -0,0 +1,13 @@
+package slogcontext_test
+
+import (
+	"testing"
+
+	"github.com/jellevandenhooff/slogctx/analysis/slogcontext"
+	"golang.org/x/tools/go/analysis/analysistest"
+)
+
+func TestAnalyzer(t *testing.T) {
+	testdata := analysistest.TestData()
+	analysistest.RunWithSuggestedFixes(t, testdata, slogcontext.Analyzer, "a", "b")
+}
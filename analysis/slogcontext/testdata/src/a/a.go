@@ -0,0 +1,47 @@
+package a
+
+import (
+	"context"
+	"errors"
+
+	"golang.org/x/exp/slog"
+)
+
+func withoutContext(logger *slog.Logger) {
+	slog.Info("no context")
+	logger.Info("no context")
+}
+
+func withContext(ctx context.Context, logger *slog.Logger) {
+	slog.Info("hi", "a", 1)                // want `slog.Info call without context while ctx is in scope; use slogctx.Info\(ctx, ...\)`
+	slog.Error("failed", errors.New("x")) // want `slog.Error call without context`
+	slog.Log(0, "log")                    // want `slog.Log call without context`
+	logger.Warn("warn")                   // want `Warn call without context while ctx is in scope; use WithContext\(ctx\)`
+	logger.With("a", 1).Debug("debug")    // want `Debug call without context`
+	slog.Default().LogAttrs(0, "attrs")   // want `LogAttrs call without context`
+
+	logger.WithContext(ctx).Info("ok")
+	ctxLogger := logger.WithContext(ctx)
+	ctxLogger.Info("ok")
+	var ctxLogger2 = slog.Default().WithContext(ctx)
+	ctxLogger2.Info("ok")
+	slog.Default().WithContext(ctx).Info("ok")
+	if logger.Enabled(0) {
+	}
+
+	func() {
+		slog.Info("closure") // want `slog.Info call without context`
+	}()
+}
+
+func local(logger *slog.Logger) {
+	slog.Info("before")
+	reqCtx := context.Background()
+	logger.Info("after") // want `Info call without context while reqCtx is in scope; use WithContext\(reqCtx\)`
+	_ = reqCtx
+}
+
+func preferCtx(other, ctx context.Context) {
+	slog.Debug("debug") // want `slog.Debug call without context while ctx is in scope`
+	_ = other
+}
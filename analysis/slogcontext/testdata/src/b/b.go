@@ -0,0 +1,9 @@
+package b
+
+import "golang.org/x/exp/slog"
+
+import "context"
+
+func handle(ctx context.Context) {
+	slog.Warn("warn") // want `slog.Warn call without context`
+}
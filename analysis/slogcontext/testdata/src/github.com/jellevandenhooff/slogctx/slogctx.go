@@ -0,0 +1,14 @@
+// Package slogctx is a stub of github.com/jellevandenhooff/slogctx for tests.
+package slogctx
+
+import (
+	"context"
+
+	"golang.org/x/exp/slog"
+)
+
+func Debug(ctx context.Context, msg string, args ...any)                 {}
+func Info(ctx context.Context, msg string, args ...any)                  {}
+func Warn(ctx context.Context, msg string, args ...any)                  {}
+func Error(ctx context.Context, msg string, err error, args ...any)      {}
+func Log(ctx context.Context, level slog.Level, msg string, args ...any) {}
@@ -0,0 +1,29 @@
+// Package slog is a stub of golang.org/x/exp/slog for tests.
+package slog
+
+import "context"
+
+type Level int
+
+type Attr struct{}
+
+type Logger struct{}
+
+func Default() *Logger { return nil }
+
+func (l *Logger) WithContext(ctx context.Context) *Logger { return l }
+func (l *Logger) With(args ...any) *Logger                { return l }
+func (l *Logger) Enabled(level Level) bool                { return true }
+
+func (l *Logger) Debug(msg string, args ...any)                   {}
+func (l *Logger) Info(msg string, args ...any)                    {}
+func (l *Logger) Warn(msg string, args ...any)                    {}
+func (l *Logger) Error(msg string, err error, args ...any)        {}
+func (l *Logger) Log(level Level, msg string, args ...any)        {}
+func (l *Logger) LogAttrs(level Level, msg string, attrs ...Attr) {}
+
+func Debug(msg string, args ...any)            {}
+func Info(msg string, args ...any)             {}
+func Warn(msg string, args ...any)             {}
+func Error(msg string, err error, args ...any) {}
+func Log(level Level, msg string, args ...any) {}
@@ -0,0 +1,302 @@
+package slogctx
+
+import (
+	"context"
+	"sync"
+
+	"golang.org/x/exp/slices"
+	"golang.org/x/exp/slog"
+)
+
+// AsyncPolicy determines what an AsyncHandler does when its queue is full.
+type AsyncPolicy int
+
+const (
+	// AsyncBlock blocks the logging goroutine until there is space in the
+	// queue.
+	AsyncBlock AsyncPolicy = iota
+	// AsyncDropNewest drops the record being logged.
+	AsyncDropNewest
+	// AsyncDropOldest drops the oldest record in the queue.
+	AsyncDropOldest
+)
+
+// DroppedKey is the key used by AsyncHandler for the number of dropped
+// records. The associated value is an int.
+const DroppedKey = "dropped"
+
+// DefaultAsyncQueueSize is the size of the queue of an AsyncHandler if
+// AsyncOptions.QueueSize is zero.
+const DefaultAsyncQueueSize = 1024
+
+// AsyncOptions are options for an AsyncHandler. A zero AsyncOptions consists
+// entirely of default values.
+type AsyncOptions struct {
+	// QueueSize is the maximum number of queued records. If QueueSize is
+	// zero, DefaultAsyncQueueSize is used.
+	QueueSize int
+
+	// Policy determines what happens when the queue is full. The default
+	// policy is AsyncBlock.
+	Policy AsyncPolicy
+}
+
+// AsyncHandler is a handler that queues records and passes them to an inner
+// handler on a background goroutine, so that logging does not block on I/O.
+//
+// Records are resolved when they are queued: the values of all attributes,
+// including attributes from the context, are resolved, and functions passed
+// to Lazy and LazyAttrs are called. If the inner handler implements
+// ContextAttrsHandler, attributes added to the context with WithAttrs are
+// passed on so the inner handler can cache their encoding, except for
+// segments containing LogValuers, which are resolved when queued and not
+// cached. When records are dropped because the queue is full, the
+// AsyncHandler logs a record at slog.LevelWarn with the number of dropped
+// records before the next record, with the time of the next record.
+//
+// Use Flush to wait for all queued records to be handled, and Close to stop
+// the background goroutine. Wrap the AsyncHandler, not its inner handler, with
+// WrapWithCtxHandler.
+type AsyncHandler struct {
+	inner slog.Handler
+	q     *asyncQueue
+}
+
+// asyncEntry is a queued record and the handler to pass it to.
+type asyncEntry struct {
+	h        slog.Handler
+	r        slog.Record
+	ctxAttrs *ContextAttrs
+}
+
+// asyncQueue is the queue shared by an AsyncHandler and all handlers derived
+// from it.
+type asyncQueue struct {
+	base   slog.Handler
+	policy AsyncPolicy
+
+	mu   sync.Mutex
+	cond sync.Cond // broadcast on every change to the fields below
+
+	entries []asyncEntry // ring buffer
+	head    int
+	n       int
+	dropped int
+	busy    bool // the background goroutine is handling an entry
+	closed  bool
+
+	done chan struct{}
+}
+
+// NewAsyncHandler returns an AsyncHandler passing records to inner.
+func NewAsyncHandler(inner slog.Handler, opts AsyncOptions) *AsyncHandler {
+	size := opts.QueueSize
+	if size == 0 {
+		size = DefaultAsyncQueueSize
+	}
+	q := &asyncQueue{
+		base:    inner,
+		policy:  opts.Policy,
+		entries: make([]asyncEntry, size),
+		done:    make(chan struct{}),
+	}
+	q.cond.L = &q.mu
+	go q.run()
+	return &AsyncHandler{inner: inner, q: q}
+}
+
+// Enabled implements Handler.
+func (h *AsyncHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.inner.Enabled(ctx, level)
+}
+
+// Handle implements Handler. It queues the record.
+func (h *AsyncHandler) Handle(r slog.Record) error {
+	return h.q.push(asyncEntry{h: h.inner, r: resolveRecord(r, nil)})
+}
+
+// HandleContextAttrs implements ContextAttrsHandler. It queues the record and
+// the attributes from the context. If the inner handler does not implement
+// ContextAttrsHandler, the attributes are added to the record.
+func (h *AsyncHandler) HandleContextAttrs(r slog.Record, attrs ContextAttrs) error {
+	if _, ok := h.inner.(ContextAttrsHandler); !ok {
+		var ctxAttrs []slog.Attr
+		attrs.Attrs(func(a slog.Attr) {
+			ctxAttrs = append(ctxAttrs, a)
+		})
+		return h.q.push(asyncEntry{h: h.inner, r: resolveRecord(r, ctxAttrs)})
+	}
+
+	// Collect the segments now, as calling Range later would resolve
+	// attributes created by LazyAttrs on the background goroutine.
+	var segs []AttrSegment
+	attrs.Range(func(seg AttrSegment) {
+		segs = append(segs, resolveSegment(seg))
+	})
+	return h.q.push(asyncEntry{h: h.inner, r: resolveRecord(r, nil), ctxAttrs: &ContextAttrs{segs: segs}})
+}
+
+// WithAttrs implements Handler.
+func (h *AsyncHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &AsyncHandler{inner: h.inner.WithAttrs(attrs), q: h.q}
+}
+
+// WithGroup implements Handler.
+func (h *AsyncHandler) WithGroup(name string) slog.Handler {
+	return &AsyncHandler{inner: h.inner.WithGroup(name), q: h.q}
+}
+
+// Flush waits until all queued records have been handled, and then flushes
+// the inner handler if it implements Flusher.
+func (h *AsyncHandler) Flush() error {
+	q := h.q
+	q.mu.Lock()
+	for q.n > 0 || q.busy {
+		q.cond.Wait()
+	}
+	q.mu.Unlock()
+	return flushHandler(q.base)
+}
+
+// Close handles all queued records, stops the background goroutine, and
+// closes the inner handler if it implements Closer, or otherwise flushes it.
+// Records logged after Close are handled synchronously.
+func (h *AsyncHandler) Close() error {
+	q := h.q
+	q.mu.Lock()
+	q.closed = true
+	q.cond.Broadcast()
+	q.mu.Unlock()
+	<-q.done
+	return closeHandler(q.base)
+}
+
+// push queues e, or handles it synchronously if the queue is closed.
+func (q *asyncQueue) push(e asyncEntry) error {
+	q.mu.Lock()
+	for !q.closed && q.n == len(q.entries) {
+		switch q.policy {
+		case AsyncDropNewest:
+			q.dropped++
+			q.mu.Unlock()
+			return nil
+		case AsyncDropOldest:
+			q.pop()
+			q.dropped++
+		default:
+			q.cond.Wait()
+		}
+	}
+	if q.closed {
+		q.mu.Unlock()
+		return e.handle()
+	}
+	q.entries[(q.head+q.n)%len(q.entries)] = e
+	q.n++
+	q.cond.Broadcast()
+	q.mu.Unlock()
+	return nil
+}
+
+// pop removes and returns the oldest entry. q.mu must be held.
+func (q *asyncQueue) pop() asyncEntry {
+	e := q.entries[q.head]
+	q.entries[q.head] = asyncEntry{}
+	q.head = (q.head + 1) % len(q.entries)
+	q.n--
+	return e
+}
+
+// run handles queued entries until the queue is closed and empty.
+func (q *asyncQueue) run() {
+	q.mu.Lock()
+	for {
+		for q.n == 0 && !q.closed {
+			q.cond.Wait()
+		}
+		if q.n == 0 {
+			break
+		}
+		e := q.pop()
+		dropped := q.dropped
+		q.dropped = 0
+		q.busy = true
+		q.cond.Broadcast()
+		q.mu.Unlock()
+
+		if dropped > 0 {
+			r := slog.NewRecord(e.r.Time, slog.LevelWarn, "dropped log records", 0, nil)
+			r.AddAttrs(slog.Int(DroppedKey, dropped))
+			_ = q.base.Handle(r)
+		}
+		_ = e.handle()
+
+		q.mu.Lock()
+		q.busy = false
+		q.cond.Broadcast()
+	}
+	q.mu.Unlock()
+	close(q.done)
+}
+
+// handle passes the entry to its handler.
+func (e asyncEntry) handle() error {
+	if e.ctxAttrs != nil {
+		return e.h.(ContextAttrsHandler).HandleContextAttrs(e.r, *e.ctxAttrs)
+	}
+	return e.h.Handle(e.r)
+}
+
+// resolveRecord returns a copy of r with extra attributes added and all
+// values resolved, that shares no state with r.
+func resolveRecord(r slog.Record, extra []slog.Attr) slog.Record {
+	attrs := make([]slog.Attr, 0, r.NumAttrs()+len(extra))
+	r.Attrs(func(a slog.Attr) {
+		attrs = append(attrs, resolveAttr(a))
+	})
+	for _, a := range extra {
+		attrs = append(attrs, resolveAttr(a))
+	}
+	resolved := slog.NewRecord(r.Time, r.Level, r.Message, r.PC, r.Context)
+	resolved.AddAttrs(attrs...)
+	return resolved
+}
+
+// resolveSegment returns seg, or an uncached copy of seg with its values
+// resolved if it contains LogValuers.
+func resolveSegment(seg AttrSegment) AttrSegment {
+	if !slices.ContainsFunc(seg.attrs, hasLogValuer) {
+		return seg
+	}
+	attrs := make([]slog.Attr, len(seg.attrs))
+	for i, a := range seg.attrs {
+		attrs[i] = resolveAttr(a)
+	}
+	return AttrSegment{attrs: attrs}
+}
+
+// hasLogValuer reports whether the value of a, or a value in its group, is a
+// LogValuer.
+func hasLogValuer(a slog.Attr) bool {
+	switch a.Value.Kind() {
+	case slog.KindLogValuer:
+		return true
+	case slog.KindGroup:
+		return slices.ContainsFunc(a.Value.Group(), hasLogValuer)
+	}
+	return false
+}
+
+// resolveAttr returns a with its value, and the values in groups, resolved.
+func resolveAttr(a slog.Attr) slog.Attr {
+	a.Value = a.Value.Resolve()
+	if a.Value.Kind() == slog.KindGroup {
+		group := a.Value.Group()
+		resolved := make([]slog.Attr, len(group))
+		for i, aa := range group {
+			resolved[i] = resolveAttr(aa)
+		}
+		a.Value = slog.GroupValue(resolved...)
+	}
+	return a
+}
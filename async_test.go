@@ -0,0 +1,178 @@
+package slogctx_test
+
+import (
+	"bytes"
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jellevandenhooff/slogctx"
+	"golang.org/x/exp/slog"
+)
+
+// gatedHandler blocks in Handle until release is closed. started is closed
+// when Handle is first called.
+type gatedHandler struct {
+	slog.Handler
+	once    *sync.Once
+	started chan struct{}
+	release chan struct{}
+}
+
+func newGatedHandler(inner slog.Handler) gatedHandler {
+	return gatedHandler{
+		Handler: inner,
+		once:    new(sync.Once),
+		started: make(chan struct{}),
+		release: make(chan struct{}),
+	}
+}
+
+func (h gatedHandler) Handle(r slog.Record) error {
+	h.once.Do(func() { close(h.started) })
+	<-h.release
+	return h.Handler.Handle(r)
+}
+
+// gatedContextAttrsHandler is a gatedHandler implementing ContextAttrsHandler.
+type gatedContextAttrsHandler struct {
+	gatedHandler
+}
+
+func (h gatedContextAttrsHandler) HandleContextAttrs(r slog.Record, attrs slogctx.ContextAttrs) error {
+	h.once.Do(func() { close(h.started) })
+	<-h.release
+	return h.Handler.(slogctx.ContextAttrsHandler).HandleContextAttrs(r, attrs)
+}
+
+func TestAsyncHandlerPolicies(t *testing.T) {
+	testCases := []struct {
+		policy slogctx.AsyncPolicy
+		want   string
+	}{
+		{
+			policy: slogctx.AsyncDropNewest,
+			want:   `level=INFO msg=r0~level=WARN msg="dropped log records" dropped=2~level=INFO msg=r1~level=INFO msg=r2`,
+		},
+		{
+			policy: slogctx.AsyncDropOldest,
+			want:   `level=INFO msg=r0~level=WARN msg="dropped log records" dropped=2~level=INFO msg=r3~level=INFO msg=r4`,
+		},
+	}
+
+	for _, tc := range testCases {
+		var buf bytes.Buffer
+		gated := newGatedHandler(slog.HandlerOptions{}.NewTextHandler(&buf))
+		h := slogctx.NewAsyncHandler(gated, slogctx.AsyncOptions{QueueSize: 2, Policy: tc.policy})
+		logger := slog.New(h)
+
+		// r0 is taken off the queue and blocks the background goroutine, r1
+		// and r2 fill the queue, and r3 and r4 do not fit.
+		logger.Info("r0")
+		<-gated.started
+		for _, msg := range []string{"r1", "r2", "r3", "r4"} {
+			logger.Info(msg)
+		}
+		close(gated.release)
+
+		if err := h.Flush(); err != nil {
+			t.Fatal(err)
+		}
+		checkLogOutput(t, timeAttrRE.ReplaceAllString(buf.String(), ""), tc.want)
+		if err := h.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestAsyncHandlerBlock(t *testing.T) {
+	var buf bytes.Buffer
+	gated := newGatedHandler(slog.HandlerOptions{}.NewTextHandler(&buf))
+	h := slogctx.NewAsyncHandler(gated, slogctx.AsyncOptions{QueueSize: 1})
+	logger := slog.New(h)
+
+	logger.Info("r0")
+	<-gated.started
+	logger.Info("r1")
+
+	done := make(chan struct{})
+	go func() {
+		logger.Info("r2")
+		close(done)
+	}()
+	select {
+	case <-done:
+		t.Fatal("expected logging to block while the queue is full")
+	case <-time.After(10 * time.Millisecond):
+	}
+
+	close(gated.release)
+	<-done
+	if err := h.Close(); err != nil {
+		t.Fatal(err)
+	}
+	checkLogOutput(t, timeAttrRE.ReplaceAllString(buf.String(), ""), `level=INFO msg=r0~level=INFO msg=r1~level=INFO msg=r2`)
+}
+
+// counterValuer is a LogValuer returning the current value of a counter.
+type counterValuer struct {
+	n *int
+}
+
+func (v counterValuer) LogValue() slog.Value {
+	return slog.IntValue(*v.n)
+}
+
+func TestAsyncHandlerResolve(t *testing.T) {
+	for _, ctxAttrs := range []bool{false, true} {
+		var buf bytes.Buffer
+		var inner slog.Handler
+		gated := newGatedHandler(slog.HandlerOptions{}.NewTextHandler(&buf))
+		inner = gated
+		if ctxAttrs {
+			gated = newGatedHandler(slogctx.NewTextHandler(&buf, slog.HandlerOptions{}))
+			inner = gatedContextAttrsHandler{gated}
+		}
+		h := slogctx.NewAsyncHandler(inner, slogctx.AsyncOptions{})
+		logger := slogctx.NewLogger(slog.New(slogctx.WrapWithCtxHandler(h)))
+
+		n := 1
+		ctx := context.Background()
+		ctx = slogctx.WithAttrs(ctx, "a", "b", "c", counterValuer{&n})
+		ctx = slogctx.LazyAttrs(ctx, func() []any {
+			return []any{"lazy", n}
+		})
+
+		logger.Info(ctx, "first", "n", counterValuer{&n})
+		<-gated.started
+		logger.Info(ctx, "second", slog.Group("g", slog.Any("n", counterValuer{&n})))
+		n = 2
+		close(gated.release)
+
+		if err := h.Close(); err != nil {
+			t.Fatal(err)
+		}
+		checkLogOutput(t, timeAttrRE.ReplaceAllString(buf.String(), ""), `level=INFO msg=first n=1 a=b c=1 lazy=1~level=INFO msg=second g.n=1 a=b c=1 lazy=1`)
+
+		// After Close, records are handled synchronously.
+		buf.Reset()
+		logger.Info(ctx, "closed")
+		checkLogOutput(t, timeAttrRE.ReplaceAllString(buf.String(), ""), `level=INFO msg=closed a=b c=2 lazy=2`)
+	}
+}
+
+func TestAsyncHandlerWithAttrs(t *testing.T) {
+	var buf bytes.Buffer
+	h := slogctx.NewAsyncHandler(slog.HandlerOptions{}.NewTextHandler(&buf), slogctx.AsyncOptions{})
+	logger := slog.New(h).With("a", 1).WithGroup("g")
+
+	logger.Info("hi", "b", 2)
+	if err := h.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	checkLogOutput(t, timeAttrRE.ReplaceAllString(buf.String(), ""), `level=INFO msg=hi a=1 g.b=2`)
+	if err := h.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,129 @@
+package slogctx_test
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"testing"
+
+	"github.com/jellevandenhooff/slogctx"
+	"golang.org/x/exp/slog"
+)
+
+// BenchmarkWithAttrsChain measures building a context by adding one
+// attribute per layer.
+func BenchmarkWithAttrsChain(b *testing.B) {
+	for _, depth := range []int{1, 10, 100, 1000} {
+		b.Run(fmt.Sprintf("depth=%d", depth), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				ctx := context.Background()
+				for j := 0; j < depth; j++ {
+					ctx = slogctx.WithAttrs(ctx, "key", j)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkKeySetChain measures setting a typed key that is new to, or
+// already in, a context built by adding one attribute per layer.
+func BenchmarkKeySetChain(b *testing.B) {
+	key := slogctx.NewKey[int]("key")
+	for _, depth := range []int{1, 10, 100} {
+		ctx := context.Background()
+		for j := 0; j < depth; j++ {
+			ctx = slogctx.WithAttrs(ctx, "attr", j)
+		}
+		b.Run(fmt.Sprintf("new/depth=%d", depth), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				key.Set(ctx, i)
+			}
+		})
+		set := slogctx.WithAttrs(key.Set(context.Background(), 0), "attr", 0)
+		for j := 1; j < depth; j++ {
+			set = slogctx.WithAttrs(set, "attr", j)
+		}
+		b.Run(fmt.Sprintf("existing/depth=%d", depth), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				key.Set(set, i)
+			}
+		})
+	}
+}
+
+// BenchmarkHandleChain measures logging with a context built by adding one
+// attribute per layer.
+func BenchmarkHandleChain(b *testing.B) {
+	logger := slogctx.NewLogger(slog.New(slogctx.WrapWithCtxHandler(slog.HandlerOptions{}.NewTextHandler(io.Discard))))
+
+	for _, depth := range []int{1, 4, 10, 100} {
+		b.Run(fmt.Sprintf("depth=%d", depth), func(b *testing.B) {
+			ctx := context.Background()
+			for j := 0; j < depth; j++ {
+				ctx = slogctx.WithAttrs(ctx, "key", j)
+			}
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				logger.Info(ctx, "hello", "attr", i)
+			}
+		})
+	}
+}
+
+// BenchmarkLoggers compares logging with plain slog to logging with a
+// wrapped handler, with and without groups. Grouped loggers allocate once per
+// record, for the attributes of the rebuilt groups.
+func BenchmarkLoggers(b *testing.B) {
+	inner := slog.HandlerOptions{}.NewTextHandler(io.Discard)
+	wrapped := slogctx.WrapWithCtxHandler(inner)
+
+	ctx := context.Background()
+	attrCtx := slogctx.WithAttrs(ctx, "requestID", "1234")
+
+	for _, bc := range []struct {
+		name   string
+		logger *slog.Logger
+		ctx    context.Context
+	}{
+		{"slog", slog.New(inner), ctx},
+		{"slog-groups", slog.New(inner).With("a", 1).WithGroup("g1").With("b", 2).WithGroup("g2"), ctx},
+		{"wrapped", slog.New(wrapped), ctx},
+		{"wrapped-attrs", slog.New(wrapped), attrCtx},
+		{"wrapped-groups", slog.New(wrapped).With("a", 1).WithGroup("g1").With("b", 2).WithGroup("g2"), ctx},
+		{"wrapped-groups-attrs", slog.New(wrapped).With("a", 1).WithGroup("g1").With("b", 2).WithGroup("g2"), attrCtx},
+	} {
+		b.Run(bc.name, func(b *testing.B) {
+			logger := bc.logger.WithContext(bc.ctx)
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				logger.Info("hello", "attr", i, "other", "value")
+			}
+		})
+	}
+}
+
+// BenchmarkContextAttrs compares logging with context attributes to slog's
+// text handler and to slogctx's text handler, which caches their encoding.
+func BenchmarkContextAttrs(b *testing.B) {
+	ctx := slogctx.WithAttrs(context.Background(), "requestID", "1234", "user", "someone", "path", "/some/path")
+
+	for _, bc := range []struct {
+		name  string
+		inner slog.Handler
+	}{
+		{"slog", slog.HandlerOptions{}.NewTextHandler(io.Discard)},
+		{"slogctx", slogctx.NewTextHandler(io.Discard, slog.HandlerOptions{})},
+	} {
+		b.Run(bc.name, func(b *testing.B) {
+			logger := slog.New(slogctx.WrapWithCtxHandler(bc.inner)).WithContext(ctx)
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				logger.Info("hello", "attr", i)
+			}
+		})
+	}
+}
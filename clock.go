@@ -0,0 +1,81 @@
+package slogctx
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"time"
+)
+
+// Clock tells the time. The ctxHandler, Start, and TraceCall use the Clock
+// installed in the context with WithClock, or the system clock.
+type Clock interface {
+	Now() time.Time
+}
+
+// IDGenerator generates unique IDs. Start and NewID use the IDGenerator
+// installed in the context with WithIDGenerator, or generate random 64-bit
+// IDs formatted as hex.
+type IDGenerator interface {
+	NewID() string
+}
+
+// systemClock is the Clock used if the context has none.
+type systemClock struct{}
+
+func (systemClock) Now() time.Time { return time.Now() }
+
+// randomIDGenerator is the IDGenerator used if the context has none.
+type randomIDGenerator struct{}
+
+// NewID returns a random 64-bit ID formatted as hex.
+func (randomIDGenerator) NewID() string {
+	var b [8]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b[:])
+}
+
+// WithClock sets the clock for all log calls using this context. The
+// ctxHandler sets the time of records to the time of the clock, and spans and
+// TraceCall measure durations with it. Tests can use a fake clock for stable
+// output.
+//
+// Requires a slog.Handler wrapped with WrapWithCtxHandler.
+func WithClock(ctx context.Context, clock Clock) context.Context {
+	newInfo := cloneInfo(ctx)
+	newInfo.clock = clock
+	return context.WithValue(ctx, ctxKey{}, &newInfo)
+}
+
+// WithIDGenerator sets the generator of span IDs, and of IDs returned by
+// NewID, for this context. Tests can use a fake generator for stable output.
+func WithIDGenerator(ctx context.Context, ids IDGenerator) context.Context {
+	newInfo := cloneInfo(ctx)
+	newInfo.ids = ids
+	return context.WithValue(ctx, ctxKey{}, &newInfo)
+}
+
+// NewID returns a new ID, for example a request ID, from the IDGenerator set
+// with WithIDGenerator, or a random 64-bit ID formatted as hex.
+func NewID(ctx context.Context) string {
+	return idGeneratorFrom(ctx).NewID()
+}
+
+// clockFrom returns the clock set in ctx with WithClock, or the system clock.
+func clockFrom(ctx context.Context) Clock {
+	if info, ok := ctx.Value(ctxKey{}).(*ctxInfo); ok && info.clock != nil {
+		return info.clock
+	}
+	return systemClock{}
+}
+
+// idGeneratorFrom returns the ID generator set in ctx with WithIDGenerator, or
+// a random ID generator.
+func idGeneratorFrom(ctx context.Context) IDGenerator {
+	if info, ok := ctx.Value(ctxKey{}).(*ctxInfo); ok && info.ids != nil {
+		return info.ids
+	}
+	return randomIDGenerator{}
+}
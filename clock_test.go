@@ -0,0 +1,47 @@
+package slogctx_test
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jellevandenhooff/slogctx"
+	"github.com/jellevandenhooff/slogctx/slogctxtest"
+	"golang.org/x/exp/slog"
+)
+
+func TestWithClockAndIDGenerator(t *testing.T) {
+	var buf bytes.Buffer
+	handler := slogctx.WrapWithCtxHandler(slog.HandlerOptions{Level: slogctx.LevelTrace}.NewTextHandler(&buf))
+	logger := slogctx.NewLogger(slog.New(handler))
+
+	ctx := context.Background()
+	ctx = slogctx.WithClock(ctx, slogctxtest.NewFakeClock(time.Date(2023, 1, 2, 3, 4, 5, 0, time.UTC), time.Second))
+	ctx = slogctx.WithIDGenerator(ctx, &slogctxtest.FakeIDGenerator{})
+
+	logger.Info(ctx, "hi", "requestID", slogctx.NewID(ctx))
+	spanCtx, end := logger.Start(ctx, "span")
+	logger.Info(spanCtx, "inside")
+	end(nil)
+
+	checkLogOutput(t, buf.String(), `time=2023-01-02T03:04:05.000Z level=INFO msg=hi requestID=0000000000000001~`+
+		`time=2023-01-02T03:04:07.000Z level=INFO msg="span started" spanID=0000000000000002~`+
+		`time=2023-01-02T03:04:08.000Z level=INFO msg=inside spanID=0000000000000002~`+
+		`time=2023-01-02T03:04:10.000Z level=INFO msg="span finished" duration=3s spanID=0000000000000002`)
+}
+
+func TestTraceWithClock(t *testing.T) {
+	check := setupTestSlogHandler(t, slog.HandlerOptions{Level: slogctx.LevelTrace})
+
+	// setup slogctx
+	slogctx.WrapDefaultLoggerWithCtxHandler()
+
+	clock := slogctxtest.NewFakeClock(time.Date(2023, 1, 2, 3, 4, 5, 0, time.UTC), 0)
+	ctx := slogctx.WithClock(context.Background(), clock)
+
+	exit := slogctx.TraceCall(ctx)
+	clock.Advance(1500 * time.Millisecond)
+	exit()
+	check(`level=DEBUG-4 msg="enter slogctx_test.TestTraceWithClock" depth=0~time=2023-01-02T03:04:06.500Z level=DEBUG-4 msg="exit slogctx_test.TestTraceWithClock" duration=1.5s depth=0`)
+}
@@ -0,0 +1,298 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// schema is the schema file read by slogctx-gen.
+type schema struct {
+	Package  string   `json:"package"`
+	EventKey string   `json:"eventKey"`
+	Imports  []string `json:"imports"`
+	Events   []event  `json:"events"`
+}
+
+// event is a named log event.
+type event struct {
+	Name        string  `json:"name"`
+	Message     string  `json:"message"`
+	Level       string  `json:"level"`
+	Description string  `json:"description"`
+	Fields      []field `json:"fields"`
+}
+
+// field is a typed field of an event.
+type field struct {
+	Name        string `json:"name"`
+	Key         string `json:"key"`
+	Type        string `json:"type"`
+	Description string `json:"description"`
+}
+
+// levels maps the level names allowed in schemas to Go expressions.
+var levels = map[string]string{
+	"TRACE": "slogctx.LevelTrace",
+	"DEBUG": "slog.LevelDebug",
+	"INFO":  "slog.LevelInfo",
+	"WARN":  "slog.LevelWarn",
+	"ERROR": "slog.LevelError",
+}
+
+// attrFuncs maps field types to the slog functions creating their attributes.
+// Other types use slog.Any.
+var attrFuncs = map[string]string{
+	"string":        "String",
+	"int":           "Int",
+	"int64":         "Int64",
+	"uint64":        "Uint64",
+	"float64":       "Float64",
+	"bool":          "Bool",
+	"time.Time":     "Time",
+	"time.Duration": "Duration",
+}
+
+// reservedParams are the parameter names of the generated functions
+// preceding the fields, and the names of the packages they use.
+var reservedParams = map[string]bool{"ctx": true, "logger": true, "context": true, "slog": true, "slogctx": true}
+
+// parseSchema parses and validates a schema, and fills in defaults.
+func parseSchema(data []byte) (*schema, error) {
+	var s schema
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&s); err != nil {
+		return nil, err
+	}
+	if !token.IsIdentifier(s.Package) {
+		return nil, fmt.Errorf("invalid package name %q", s.Package)
+	}
+	imported := map[string]bool{"time": true}
+	for _, imp := range s.Imports {
+		imported[path.Base(imp)] = true
+	}
+
+	names := make(map[string]bool)
+	for i := range s.Events {
+		ev := &s.Events[i]
+		if !token.IsIdentifier(ev.Name) || !token.IsExported(ev.Name) {
+			return nil, fmt.Errorf("event %q: name must be an exported Go identifier", ev.Name)
+		}
+		if names[ev.Name] {
+			return nil, fmt.Errorf("event %s: duplicate name", ev.Name)
+		}
+		names[ev.Name] = true
+		if ev.Message == "" {
+			return nil, fmt.Errorf("event %s: missing message", ev.Name)
+		}
+		if ev.Level == "" {
+			ev.Level = "INFO"
+		}
+		if _, ok := levels[ev.Level]; !ok {
+			return nil, fmt.Errorf("event %s: unknown level %q", ev.Name, ev.Level)
+		}
+
+		keys := make(map[string]bool)
+		if s.EventKey != "" {
+			keys[s.EventKey] = true
+		}
+		params := make(map[string]bool)
+		for j := range ev.Fields {
+			f := &ev.Fields[j]
+			if !token.IsIdentifier(f.Name) || reservedParams[f.Name] || f.Name == "_" {
+				return nil, fmt.Errorf("event %s: invalid field name %q", ev.Name, f.Name)
+			}
+			if params[f.Name] {
+				return nil, fmt.Errorf("event %s: duplicate field %s", ev.Name, f.Name)
+			}
+			params[f.Name] = true
+			if f.Key == "" {
+				f.Key = f.Name
+			}
+			if keys[f.Key] {
+				return nil, fmt.Errorf("event %s: duplicate key %q", ev.Name, f.Key)
+			}
+			keys[f.Key] = true
+			if err := checkType(f.Type, imported); err != nil {
+				return nil, fmt.Errorf("event %s: field %s: %v", ev.Name, f.Name, err)
+			}
+		}
+	}
+	return &s, nil
+}
+
+// checkType checks that typ is a Go type whose qualifiers are imported.
+func checkType(typ string, imported map[string]bool) error {
+	x, err := parser.ParseExpr(typ)
+	if err != nil {
+		return fmt.Errorf("invalid type %q", typ)
+	}
+	var err2 error
+	ast.Inspect(x, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.SelectorExpr:
+			if id, ok := n.X.(*ast.Ident); ok && !imported[id.Name] && err2 == nil {
+				err2 = fmt.Errorf("type %q: package %s is not imported", typ, id.Name)
+			}
+			return false
+		case *ast.CallExpr, *ast.BinaryExpr, *ast.UnaryExpr, *ast.BasicLit, *ast.FuncLit, *ast.CompositeLit:
+			if err2 == nil {
+				err2 = fmt.Errorf("invalid type %q", typ)
+			}
+			return false
+		}
+		return true
+	})
+	return err2
+}
+
+// generateCode returns the formatted Go source of the logging functions for
+// s, generated from the schema file named source.
+func generateCode(s *schema, source string) ([]byte, error) {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "// Code generated by slogctx-gen from %s. DO NOT EDIT.\n\n", source)
+	fmt.Fprintf(&b, "package %s\n\n", s.Package)
+
+	imports := []string{"context", "github.com/jellevandenhooff/slogctx", "golang.org/x/exp/slog"}
+	imports = append(imports, s.Imports...)
+	if usesTime(s) && !contains(imports, "time") {
+		imports = append(imports, "time")
+	}
+	sort.Strings(imports)
+	b.WriteString("import (\n")
+	// Standard library packages first, then the others.
+	for _, std := range []bool{true, false} {
+		if !std {
+			b.WriteString("\n")
+		}
+		for _, imp := range imports {
+			first, _, _ := strings.Cut(imp, "/")
+			if strings.Contains(first, ".") != std {
+				fmt.Fprintf(&b, "\t%s\n", strconv.Quote(imp))
+			}
+		}
+	}
+	b.WriteString(")\n")
+
+	for _, ev := range s.Events {
+		b.WriteString("\n")
+		fmt.Fprintf(&b, "// Log%s logs the %s event at %s.\n", ev.Name, ev.Name, ev.Level)
+		if ev.Description != "" {
+			b.WriteString("//\n")
+			writeComment(&b, ev.Description)
+		}
+		fmt.Fprintf(&b, "func Log%s(ctx context.Context, logger *slogctx.Logger", ev.Name)
+		for _, f := range ev.Fields {
+			fmt.Fprintf(&b, ", %s %s", f.Name, f.Type)
+		}
+		b.WriteString(") {\n")
+		fmt.Fprintf(&b, "\tlogger.LogAttrsDepth(ctx, 1, %s, %s", levels[ev.Level], strconv.Quote(ev.Message))
+		if s.EventKey != "" {
+			fmt.Fprintf(&b, ",\n\t\tslog.String(%s, %s)", strconv.Quote(s.EventKey), strconv.Quote(ev.Name))
+		}
+		for _, f := range ev.Fields {
+			fn, ok := attrFuncs[f.Type]
+			if !ok {
+				fn = "Any"
+			}
+			fmt.Fprintf(&b, ",\n\t\tslog.%s(%s, %s)", fn, strconv.Quote(f.Key), f.Name)
+		}
+		b.WriteString(")\n}\n")
+	}
+
+	src, err := format.Source(b.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("formatting generated code: %v", err)
+	}
+	return src, nil
+}
+
+// usesTime reports whether a field type of s refers to package time.
+func usesTime(s *schema) bool {
+	for _, ev := range s.Events {
+		for _, f := range ev.Fields {
+			x, err := parser.ParseExpr(f.Type)
+			if err != nil {
+				continue
+			}
+			found := false
+			ast.Inspect(x, func(n ast.Node) bool {
+				if sel, ok := n.(*ast.SelectorExpr); ok {
+					if id, ok := sel.X.(*ast.Ident); ok && id.Name == "time" {
+						found = true
+					}
+				}
+				return !found
+			})
+			if found {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func contains(list []string, s string) bool {
+	for _, x := range list {
+		if x == s {
+			return true
+		}
+	}
+	return false
+}
+
+// writeComment writes text as a Go comment.
+func writeComment(b *bytes.Buffer, text string) {
+	for _, line := range strings.Split(strings.TrimSpace(text), "\n") {
+		line = strings.TrimRightFunc(line, unicode.IsSpace)
+		if line == "" {
+			b.WriteString("//\n")
+		} else {
+			fmt.Fprintf(b, "// %s\n", line)
+		}
+	}
+}
+
+// generateCatalog returns a Markdown document describing the events of s,
+// generated from the schema file named source.
+func generateCatalog(s *schema, source string) []byte {
+	var b bytes.Buffer
+	b.WriteString("# Log events\n\n")
+	fmt.Fprintf(&b, "<!-- Code generated by slogctx-gen from %s. DO NOT EDIT. -->\n\n", source)
+	b.WriteString("| Event | Level | Message |\n| --- | --- | --- |\n")
+	for _, ev := range s.Events {
+		fmt.Fprintf(&b, "| [%s](#%s) | %s | %s |\n", ev.Name, strings.ToLower(ev.Name), ev.Level, markdownCode(ev.Message))
+	}
+
+	for _, ev := range s.Events {
+		fmt.Fprintf(&b, "\n## %s\n\n", ev.Name)
+		if ev.Description != "" {
+			fmt.Fprintf(&b, "%s\n\n", strings.TrimSpace(ev.Description))
+		}
+		fmt.Fprintf(&b, "Logged at %s with message %s by `Log%s`.\n\n", ev.Level, markdownCode(ev.Message), ev.Name)
+		b.WriteString("| Key | Type | Description |\n| --- | --- | --- |\n")
+		if s.EventKey != "" {
+			fmt.Fprintf(&b, "| %s | `string` | Always %s. |\n", markdownCode(s.EventKey), markdownCode(ev.Name))
+		}
+		for _, f := range ev.Fields {
+			desc := strings.Join(strings.Fields(f.Description), " ")
+			fmt.Fprintf(&b, "| %s | %s | %s |\n", markdownCode(f.Key), markdownCode(f.Type), strings.ReplaceAll(desc, "|", `\|`))
+		}
+	}
+	return b.Bytes()
+}
+
+// markdownCode formats s as inline code in a Markdown table cell.
+func markdownCode(s string) string {
+	return "`" + strings.ReplaceAll(s, "|", `\|`) + "`"
+}
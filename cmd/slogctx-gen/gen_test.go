@@ -0,0 +1,66 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "update golden files in testdata")
+
+func TestGenerate(t *testing.T) {
+	dir := t.TempDir()
+	out := filepath.Join(dir, "events_log.go")
+	doc := filepath.Join(dir, "EVENTS.md")
+	if err := run("testdata/events.json", out, doc); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct{ got, golden string }{
+		{out, "testdata/events_log.go.golden"},
+		{doc, "testdata/EVENTS.md.golden"},
+	} {
+		got, err := os.ReadFile(tc.got)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if *update {
+			if err := os.WriteFile(tc.golden, got, 0o644); err != nil {
+				t.Fatal(err)
+			}
+			continue
+		}
+		want, err := os.ReadFile(tc.golden)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != string(want) {
+			t.Errorf("%s:\ngot:\n%s\nwant:\n%s", tc.golden, got, want)
+		}
+	}
+}
+
+func TestParseSchemaErrors(t *testing.T) {
+	for _, tc := range []struct {
+		schema, err string
+	}{
+		{`{"package": "a-b"}`, `invalid package name "a-b"`},
+		{`{"package": "p", "unknown": 1}`, `unknown field "unknown"`},
+		{`{"package": "p", "events": [{"name": "lower", "message": "m"}]}`, `event "lower": name must be an exported Go identifier`},
+		{`{"package": "p", "events": [{"name": "E", "message": "m"}, {"name": "E", "message": "m"}]}`, `event E: duplicate name`},
+		{`{"package": "p", "events": [{"name": "E"}]}`, `event E: missing message`},
+		{`{"package": "p", "events": [{"name": "E", "message": "m", "level": "FATAL"}]}`, `event E: unknown level "FATAL"`},
+		{`{"package": "p", "events": [{"name": "E", "message": "m", "fields": [{"name": "ctx", "type": "int"}]}]}`, `event E: invalid field name "ctx"`},
+		{`{"package": "p", "events": [{"name": "E", "message": "m", "fields": [{"name": "a", "type": "int"}, {"name": "b", "key": "a", "type": "int"}]}]}`, `event E: duplicate key "a"`},
+		{`{"package": "p", "eventKey": "event", "events": [{"name": "E", "message": "m", "fields": [{"name": "event", "type": "int"}]}]}`, `event E: duplicate key "event"`},
+		{`{"package": "p", "events": [{"name": "E", "message": "m", "fields": [{"name": "a", "type": "netip.Addr"}]}]}`, `event E: field a: type "netip.Addr": package netip is not imported`},
+		{`{"package": "p", "events": [{"name": "E", "message": "m", "fields": [{"name": "a", "type": "1 + 2"}]}]}`, `event E: field a: invalid type "1 + 2"`},
+	} {
+		_, err := parseSchema([]byte(tc.schema))
+		if err == nil || !strings.Contains(err.Error(), tc.err) {
+			t.Errorf("parseSchema(%s) = %v, want error containing %q", tc.schema, err, tc.err)
+		}
+	}
+}
@@ -0,0 +1,87 @@
+// Command slogctx-gen generates typed logging functions for events described
+// in a schema file.
+//
+// The schema is a JSON file listing events with a message, a level, and typed
+// fields:
+//
+//	{
+//		"package": "events",
+//		"eventKey": "event",
+//		"events": [{
+//			"name": "UserSignedUp",
+//			"message": "user signed up",
+//			"level": "INFO",
+//			"description": "A user completed the sign up form.",
+//			"fields": [
+//				{"name": "userID", "key": "user_id", "type": "string"},
+//				{"name": "plan", "type": "Plan", "description": "The chosen plan."}
+//			]
+//		}]
+//	}
+//
+// For each event, slogctx-gen generates a function logging the event with its
+// fixed message, level, and keys through a slogctx.Logger:
+//
+//	func LogUserSignedUp(ctx context.Context, logger *slogctx.Logger, userID string, plan Plan)
+//
+// The key of a field defaults to its name, and the level of an event to INFO.
+// If eventKey is set, records include the name of the event under that key.
+// Types are Go types; the packages of qualified types other than time must be
+// listed in "imports". slogctx-gen also writes a Markdown catalog of all
+// events. Usage:
+//
+//	//go:generate slogctx-gen -schema events.json -o events_log.go -doc EVENTS.md
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+func main() {
+	schemaPath := flag.String("schema", "", "path of the JSON schema `file`")
+	out := flag.String("o", "", "output Go `file` (default: schema file name with _log.go)")
+	doc := flag.String("doc", "", "output Markdown catalog `file`; not written if empty")
+	flag.Parse()
+	if *schemaPath == "" || flag.NArg() > 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	if *out == "" {
+		ext := filepath.Ext(*schemaPath)
+		*out = (*schemaPath)[:len(*schemaPath)-len(ext)] + "_log.go"
+	}
+
+	if err := run(*schemaPath, *out, *doc); err != nil {
+		fmt.Fprintf(os.Stderr, "slogctx-gen: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// run generates the functions and catalog for the schema file.
+func run(schemaPath, out, doc string) error {
+	data, err := os.ReadFile(schemaPath)
+	if err != nil {
+		return err
+	}
+	s, err := parseSchema(data)
+	if err != nil {
+		return fmt.Errorf("%s: %v", schemaPath, err)
+	}
+
+	src, err := generateCode(s, filepath.Base(schemaPath))
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(out, src, 0o644); err != nil {
+		return err
+	}
+	if doc != "" {
+		if err := os.WriteFile(doc, generateCatalog(s, filepath.Base(schemaPath)), 0o644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
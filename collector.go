@@ -0,0 +1,177 @@
+package slogctx
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"sync"
+
+	"golang.org/x/exp/slog"
+)
+
+// CollectorOptions are options for a Collector. A zero CollectorOptions
+// consists entirely of default values.
+type CollectorOptions struct {
+	// Level is the minimum level of collected records, also for contexts
+	// with a lower minimum level set with WithMinimumLevel. The default
+	// level is slog.LevelInfo.
+	Level slog.Leveler
+
+	// MaxRecords is the maximum number of collected records. The default
+	// is 100.
+	MaxRecords int
+
+	// MaxBytes is the maximum total size of the JSON encodings of the
+	// collected records. The default is 8 KiB, which fits in the header
+	// size limits of common HTTP servers and proxies.
+	MaxBytes int
+
+	// Redact, if non-nil, redacts the collected records with a
+	// RedactHandler. The handler of the logger does not apply to the
+	// collected records.
+	Redact *RedactOptions
+}
+
+// Collector stores the records logged using a context created by
+// WithCollector, encoded as JSON like NewJSONHandler. Records beyond the
+// limits of its CollectorOptions are dropped and counted.
+type Collector struct {
+	maxRecords int
+	maxBytes   int
+
+	mu      sync.Mutex
+	records []json.RawMessage
+	size    int
+	dropped int
+}
+
+// WithCollector returns a context whose logs, including the logs of contexts
+// derived from it, are also stored in the returned Collector. This is useful
+// to return the logs of a request to the client; see package slogctxhttp.
+// Usage:
+//
+//	ctx, c := slogctx.WithCollector(ctx, slogctx.CollectorOptions{Level: slog.LevelDebug})
+//	handle(ctx)
+//	data, err := json.Marshal(c)
+//
+// The collector is added to the context with WithHandler and HandlerTee, so
+// records are also handled by the logger's handler, and contexts derived with
+// HandlerRedirect are not collected. Levels are named with ReplaceLevelNames.
+//
+// Requires a slog.Handler wrapped with WrapWithCtxHandler.
+func WithCollector(ctx context.Context, opts CollectorOptions) (context.Context, *Collector) {
+	c := &Collector{maxRecords: opts.MaxRecords, maxBytes: opts.MaxBytes}
+	if c.maxRecords <= 0 {
+		c.maxRecords = 100
+	}
+	if c.maxBytes <= 0 {
+		c.maxBytes = 8 << 10
+	}
+	h := NewJSONHandler(collectorWriter{c}, slog.HandlerOptions{
+		Level:       opts.Level,
+		ReplaceAttr: ReplaceLevelNames,
+	}).(ContextAttrsHandler)
+	if opts.Redact != nil {
+		h = NewRedactHandler(h, *opts.Redact)
+	}
+	return WithHandler(ctx, levelHandler{h}, HandlerTee), c
+}
+
+// levelHandler drops records its inner handler is not enabled for. The
+// ctxHandler passes records to handlers added with WithHandler regardless of
+// their level if the context sets a minimum level, which a Collector must
+// not exceed to stay within its limits.
+type levelHandler struct {
+	inner ContextAttrsHandler
+}
+
+func (h levelHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.inner.Enabled(ctx, level)
+}
+
+func (h levelHandler) Handle(r slog.Record) error {
+	if !h.inner.Enabled(r.Context, r.Level) {
+		return nil
+	}
+	return h.inner.Handle(r)
+}
+
+func (h levelHandler) HandleContextAttrs(r slog.Record, attrs ContextAttrs) error {
+	if !h.inner.Enabled(r.Context, r.Level) {
+		return nil
+	}
+	return h.inner.HandleContextAttrs(r, attrs)
+}
+
+// Flush flushes the inner handler if it implements Flusher.
+func (h levelHandler) Flush() error {
+	return flushHandler(h.inner)
+}
+
+// Close closes the inner handler if it implements Closer, and otherwise
+// flushes it if it implements Flusher.
+func (h levelHandler) Close() error {
+	return closeHandler(h.inner)
+}
+
+func (h levelHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return levelHandler{h.inner.WithAttrs(attrs).(ContextAttrsHandler)}
+}
+
+func (h levelHandler) WithGroup(name string) slog.Handler {
+	return levelHandler{h.inner.WithGroup(name).(ContextAttrsHandler)}
+}
+
+// collectorWriter adds the records written by a JSON handler to a Collector.
+// The handler writes each record with a single Write call.
+type collectorWriter struct {
+	c *Collector
+}
+
+func (w collectorWriter) Write(p []byte) (int, error) {
+	w.c.add(bytes.TrimSuffix(p, []byte("\n")))
+	return len(p), nil
+}
+
+// add stores a copy of the encoded record, or counts it as dropped if it does
+// not fit.
+func (c *Collector) add(record []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.records) >= c.maxRecords || c.size+len(record) > c.maxBytes {
+		c.dropped++
+		return
+	}
+	c.records = append(c.records, append(json.RawMessage(nil), record...))
+	c.size += len(record)
+}
+
+// Records returns the JSON encodings of the collected records, oldest first.
+func (c *Collector) Records() []json.RawMessage {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]json.RawMessage(nil), c.records...)
+}
+
+// Dropped returns the number of records that were not collected because they
+// exceeded the limits.
+func (c *Collector) Dropped() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.dropped
+}
+
+// MarshalJSON encodes the collector as an object with the collected records
+// in "records" and the number of dropped records in "dropped".
+func (c *Collector) MarshalJSON() ([]byte, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	records := c.records
+	if records == nil {
+		records = []json.RawMessage{}
+	}
+	return json.Marshal(struct {
+		Records []json.RawMessage `json:"records"`
+		Dropped int               `json:"dropped"`
+	}{records, c.dropped})
+}
@@ -0,0 +1,83 @@
+package slogctx_test
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/jellevandenhooff/slogctx"
+	"golang.org/x/exp/slog"
+)
+
+func TestWithCollector(t *testing.T) {
+	var buf strings.Builder
+	logger := slog.New(slogctx.WrapWithCtxHandler(slog.HandlerOptions{}.NewTextHandler(&buf))).With("with", 1)
+
+	ctx := slogctx.WithAttrs(context.Background(), "request", 7)
+	ctx, c := slogctx.WithCollector(ctx, slogctx.CollectorOptions{Level: slogctx.LevelTrace, MaxRecords: 3})
+	logger.WithContext(ctx).Info("first", "token", slogctx.NewSecret("hunter2"))
+	logger.WithContext(slogctx.WithAttrs(ctx, "child", true)).Log(slogctx.LevelTrace, "second")
+	logger.WithContext(ctx).Debug("third")
+	logger.WithContext(ctx).Info("fourth")
+	logger.WithContext(context.Background()).Info("other")
+
+	var got []string
+	for _, r := range c.Records() {
+		var m map[string]any
+		if err := json.Unmarshal(r, &m); err != nil {
+			t.Fatalf("invalid record %s: %v", r, err)
+		}
+		delete(m, "time")
+		b, _ := json.Marshal(m)
+		got = append(got, string(b))
+	}
+	want := []string{
+		`{"level":"INFO","msg":"first","request":7,"token":"[REDACTED]","with":1}`,
+		`{"child":true,"level":"TRACE","msg":"second","request":7,"with":1}`,
+		`{"level":"DEBUG","msg":"third","request":7,"with":1}`,
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got records\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+	if got := c.Dropped(); got != 1 {
+		t.Errorf("got %d dropped records, want 1", got)
+	}
+	if n := strings.Count(buf.String(), "\n"); n != 3 {
+		t.Errorf("got %d records in the main log, want 3:\n%s", n, buf.String())
+	}
+
+	data, err := json.Marshal(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(data), `{"records":[{"time":`) || !strings.HasSuffix(string(data), `],"dropped":1}`) {
+		t.Errorf("got JSON %s", data)
+	}
+}
+
+func TestCollectorLevel(t *testing.T) {
+	logger := slog.New(slogctx.WrapWithCtxHandler(slog.HandlerOptions{}.NewTextHandler(&strings.Builder{})))
+	ctx, c := slogctx.WithCollector(context.Background(), slogctx.CollectorOptions{})
+	ctx = slogctx.WithMinimumLevel(ctx, slogctx.LevelTrace)
+	logger.WithContext(ctx).Debug("debug")
+	logger.WithContext(slogctx.WithAttrs(ctx, "a", 1)).Log(slogctx.LevelTrace, "trace")
+	logger.WithContext(ctx).Info("info")
+	if got := len(c.Records()); got != 1 {
+		t.Errorf("got %d records, want only the INFO record", got)
+	}
+}
+
+func TestCollectorMaxBytes(t *testing.T) {
+	logger := slog.New(slogctx.WrapWithCtxHandler(slog.HandlerOptions{Level: slog.LevelError}.NewTextHandler(&strings.Builder{})))
+	ctx, c := slogctx.WithCollector(context.Background(), slogctx.CollectorOptions{MaxBytes: 200})
+	for i := 0; i < 5; i++ {
+		logger.WithContext(ctx).Info("message", "i", i)
+	}
+	if got := len(c.Records()); got == 0 || got == 5 {
+		t.Errorf("got %d records, want some dropped", got)
+	}
+	if got := len(c.Records()) + c.Dropped(); got != 5 {
+		t.Errorf("got %d records and dropped records, want 5", got)
+	}
+}
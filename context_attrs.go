@@ -0,0 +1,138 @@
+package slogctx
+
+import (
+	"sync"
+
+	"golang.org/x/exp/slices"
+	"golang.org/x/exp/slog"
+)
+
+// ContextAttrsHandler is an optional interface for handlers wrapped with
+// WrapWithCtxHandler. If the inner handler implements ContextAttrsHandler,
+// the ctxHandler calls HandleContextAttrs instead of Handle, and passes the
+// attributes from the context separately from the record.
+//
+// The attributes from a context consist of segments added by individual
+// WithAttrs calls. Segments can be shared by many records logged during, for
+// example, a single request. A handler can use AttrSegment.Load and
+// AttrSegment.Store to cache the encoding of a segment, like the built-in
+// slog handlers pre-format the attributes passed to WithAttrs.
+type ContextAttrsHandler interface {
+	slog.Handler
+
+	// HandleContextAttrs handles the record like Handle, as if attrs were
+	// appended to the record's attributes. The attributes in attrs are not in
+	// any group started with WithGroup.
+	HandleContextAttrs(r slog.Record, attrs ContextAttrs) error
+}
+
+// ContextAttrs is the list of attributes a ctxHandler adds to a record.
+type ContextAttrs struct {
+	info  *ctxInfo
+	extra []slog.Attr
+
+	// segs, if non-nil, holds previously collected segments and replaces
+	// info and extra.
+	segs []AttrSegment
+}
+
+// Range calls f for each segment of attributes, oldest first.
+func (c ContextAttrs) Range(f func(seg AttrSegment)) {
+	if c.segs != nil {
+		for _, seg := range c.segs {
+			f(seg)
+		}
+		return
+	}
+	if c.info != nil {
+		c.info.rangeSegments(f)
+	}
+	if len(c.extra) > 0 {
+		f(AttrSegment{attrs: c.extra})
+	}
+}
+
+// Attrs calls f on each attribute in all segments, oldest first.
+func (c ContextAttrs) Attrs(f func(a slog.Attr)) {
+	c.Range(func(seg AttrSegment) {
+		for _, a := range seg.attrs {
+			f(a)
+		}
+	})
+}
+
+// rangeSegments calls f for the segments of info and its parents, oldest
+// first.
+func (info *ctxInfo) rangeSegments(f func(seg AttrSegment)) {
+	if info.parent != nil {
+		info.parent.rangeSegments(f)
+	}
+	if len(info.attrs) == 0 {
+		return
+	}
+	if info.cache == nil {
+		var attrs []slog.Attr
+		for _, a := range info.attrs {
+			attrs = appendResolvedLazy(attrs, a)
+		}
+		f(AttrSegment{attrs: attrs})
+		return
+	}
+	f(AttrSegment{attrs: info.attrs, cache: info.cache})
+}
+
+// AttrSegment is a list of attributes from a context.
+//
+// A segment is either added by a single WithAttrs call, and then shared by
+// all records logged using the context or contexts derived from it, or
+// specific to a single record, for example for attributes created by Lazy.
+type AttrSegment struct {
+	attrs []slog.Attr
+	cache *sync.Map
+}
+
+// Attrs returns the attributes in the segment. The caller must not modify the
+// returned slice.
+func (s AttrSegment) Attrs() []slog.Attr {
+	return s.attrs
+}
+
+// Load returns the value stored in the segment for key with Store.
+func (s AttrSegment) Load(key any) (value any, ok bool) {
+	if s.cache == nil {
+		return nil, false
+	}
+	return s.cache.Load(key)
+}
+
+// Store stores a value for key in the segment, typically the encoding of the
+// segment's attributes by a handler. It is a no-op if the segment is specific
+// to a single record. Handlers should use a key identifying their encoding,
+// for example a pointer to their shared options, and should not store the
+// encoding of a segment containing LogValuers, which can resolve to a
+// different value for each record.
+func (s AttrSegment) Store(key, value any) {
+	if s.cache != nil {
+		s.cache.Store(key, value)
+	}
+}
+
+// cacheable reports whether the encoding of the segment can be cached. It can
+// not if the segment contains LogValuers, whose values can change between
+// records, other than Secrets, which always log the same way.
+func (s AttrSegment) cacheable() bool {
+	return !slices.ContainsFunc(s.attrs, hasVariableValue)
+}
+
+// hasVariableValue reports whether the value of a, or a value in its group,
+// is a LogValuer other than a Secret.
+func hasVariableValue(a slog.Attr) bool {
+	switch a.Value.Kind() {
+	case slog.KindLogValuer:
+		_, ok := a.Value.LogValuer().(secret)
+		return !ok
+	case slog.KindGroup:
+		return slices.ContainsFunc(a.Value.Group(), hasVariableValue)
+	}
+	return false
+}
@@ -10,6 +10,12 @@
 //	logger := slogctx.Default() // or logger := slogctx.NewLogger(slog.Default())
 //	logger.Info(ctx, "found something special")
 //
+// The package adds LevelTrace below slog.LevelDebug and LevelFatal above
+// slog.LevelError, logged with slogctx.Trace and slogctx.Fatal, and the Logger
+// methods of the same names. Fatal exits the program after flushing the
+// handler. Use slogctx.ReplaceLevelNames as slog.HandlerOptions.ReplaceAttr to
+// output the levels as TRACE and FATAL.
+//
 // The package supports storing extra attributes in a context. All logs using
 // the context created by slogctx.WithAttrs will include the extra attributes.
 // This is useful to include a requestID with all logs. Usage:
@@ -18,6 +24,20 @@
 //	slogctx.Info(ctx, "processing request")
 //	slog.Default().WithContext(ctx).Info("processing more") // also works with plain slog
 //
+// Values that the program also needs to read can be stored with a typed Key
+// created by slogctx.NewKey. They are logged like attributes added with
+// WithAttrs, and read back with Key.Get. Usage:
+//
+//	var userID = slogctx.NewKey[string]("userID")
+//	ctx = userID.Set(ctx, id)
+//	id, ok := userID.Get(ctx)
+//
+// Attributes that are expensive to compute can be created with slogctx.Lazy,
+// or attached to a context with slogctx.LazyAttrs. Their functions only run
+// for logs that are actually output. Usage:
+//
+//	slogctx.Debug(ctx, "got body", slogctx.Lazy("body", func() any { return dump(body) }))
+//
 // The package supports overriding the minimum log level. All logs using the
 // context created by slogctx.WithMinimumLevel will use the supplied level. This
 // is useful to debug specific requests. Usage:
@@ -25,6 +45,93 @@
 //	ctx = slogctx.WithMinimumLevel(ctx, slog.LevelDebug)
 //	slogctx.Debug(ctx, "low-level information")
 //
+// The package supports sending the logs of a context to an extra handler.
+// All logs using the context created by slogctx.WithHandler are also, or only,
+// handled by the supplied handler. This is useful to write the logs of a batch
+// job to a per-job file. Usage:
+//
+//	ctx = slogctx.WithHandler(ctx, slog.HandlerOptions{}.NewTextHandler(jobLog), slogctx.HandlerTee)
+//
+// slogctx.WithCollector stores the logs of a context, up to a limit, as JSON in
+// a Collector, for example to return them to the client of a request. Package
+// slogctxhttp provides middleware doing so for requests with a signed debug
+// header.
+//
+// The package supports lightweight spans. slogctx.Start logs the start of an
+// operation and returns a context with a span ID and the ID of the parent span,
+// and a function that logs the end of the operation with its duration. Usage:
+//
+//	ctx, end := slogctx.Start(ctx, "fetch", "url", url)
+//	defer func() { end(err) }()
+//
+// The package supports tracing function entry and exit at LevelTrace, which is
+// only logged if enabled for the context. Usage:
+//
+//	ctx = slogctx.WithMinimumLevel(ctx, slogctx.LevelTrace)
+//	defer slogctx.TraceCall(ctx, "id", id)()
+//
+// The package supports rewriting attributes within a context. Functions added
+// with slogctx.WithReplaceAttr are applied, like slog.HandlerOptions.ReplaceAttr,
+// to the attributes of all logs using the context, including the attributes
+// from the context. Usage:
+//
+//	ctx = slogctx.WithReplaceAttr(ctx, redactBodies)
+//
+// The package supports adding a call stack to logs. Logs at or above
+// CtxHandlerOptions.StackLevel, and all logs using the context created by
+// slogctx.WithStackTrace, will include a "stack" attribute. Usage:
+//
+//	handler := slogctx.CtxHandlerOptions{StackLevel: slog.LevelError}.Wrap(inner)
+//	ctx = slogctx.WithStackTrace(ctx)
+//
+// CtxHandlerOptions.KeyPattern and CtxHandlerOptions.AllowedKeys restrict the
+// keys of attributes, including those from the context. Invalid keys are
+// rewritten, for example with SnakeCase, dropped, or reported once per call
+// site:
+//
+//	opts := slogctx.CtxHandlerOptions{KeyPattern: regexp.MustCompile(`^[a-z][a-z0-9_]*$`), InvalidKeys: slogctx.KeyRewrite}
+//
+// Handlers wrapped with WrapWithCtxHandler can implement ContextAttrsHandler
+// to receive the attributes from the context separately, and cache their
+// encoding. slogctx.NewTextHandler and slogctx.NewJSONHandler do so.
+//
+// To avoid blocking on I/O while logging, wrap a handler with
+// slogctx.NewAsyncHandler. It queues records and handles them on a background
+// goroutine, and blocks or drops records when the queue is full:
+//
+//	async := slogctx.NewAsyncHandler(inner, slogctx.AsyncOptions{Policy: slogctx.AsyncDropOldest})
+//	defer async.Close()
+//	handler := slogctx.WrapWithCtxHandler(async)
+//
+// Wrap values like tokens with slogctx.NewSecret to log them as RedactedValue.
+// A handler created with slogctx.NewRedactHandler and wrapped with
+// WrapWithCtxHandler also redacts the values of attributes with keys matching
+// patterns, including in groups and in the context, and can replace values
+// with keyed hashes to correlate them across records.
+//
+// Handlers that buffer records implement Flusher and Closer, and wrapping
+// handlers forward both to their inner handler. Call slogctx.Shutdown before
+// the program exits to flush the default logger's handler and the handlers
+// added to the context with slogctx.WithHandler.
+//
+// Record times, span and trace durations, and span IDs come from the Clock and
+// IDGenerator set with slogctx.WithClock and slogctx.WithIDGenerator, so tests
+// can use the fakes in slogctxtest for stable output. NewID returns IDs, for
+// example for requests, from the same generator.
+//
+// The slogctxvet command, also usable as a vet tool, reports slog logging
+// calls that do not pass the context in scope, and malformed key-value
+// arguments. The slogctx-migrate command rewrites such calls, and
+// *slog.Logger struct fields, to pass the context. Both are in the separate
+// module github.com/jellevandenhooff/slogctx/analysis, which requires a newer
+// Go version than this package. The slogctx-gen command
+// generates typed functions logging the events described in a schema file,
+// with fixed messages and keys, and a catalog of the events.
+//
+// In tests, use slogctxtest.New to write logs to the test's log instead.
+// Package handlertest checks that a custom handler works when wrapped with
+// WrapWithCtxHandler.
+//
 // Using WithAttrs and WithMinimumLevel requires wrapping the underlying
 // slog.Handler using slogctx.CtxHandler. This can be done globally for the
 // default logger using slogctx.WrapDefaultLoggerWithCtxHandler.
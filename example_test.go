@@ -6,20 +6,14 @@ import (
 	"time"
 
 	"github.com/jellevandenhooff/slogctx"
+	"github.com/jellevandenhooff/slogctx/slogctxtest"
 	"golang.org/x/exp/slog"
 )
 
 func SetupSlogForExample() func() {
 	original := slog.Default()
 
-	slog.SetDefault(slog.New(slog.HandlerOptions{
-		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
-			if a.Key == "time" {
-				return slog.Time("time", time.Date(2022, 1, 29, 15, 10, 0, 0, time.UTC))
-			}
-			return a
-		},
-	}.NewTextHandler(os.Stdout)))
+	slog.SetDefault(slog.New(slog.HandlerOptions{}.NewTextHandler(os.Stdout)))
 
 	return func() {
 		slog.SetDefault(original)
@@ -32,6 +26,9 @@ func Example() {
 
 	ctx := context.Background()
 
+	// Use a fixed clock to get stable output in this example.
+	ctx = slogctx.WithClock(ctx, slogctxtest.NewFakeClock(time.Date(2022, 1, 29, 15, 10, 0, 0, time.UTC), 0))
+
 	// Setup slogctx for slog.Default(). This is required to make slog.WithAttrs
 	// and slog.WithMinimumLevel work.
 	slogctx.WrapDefaultLoggerWithCtxHandler()
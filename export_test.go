@@ -0,0 +1,25 @@
+package slogctx
+
+import "context"
+
+// SetExitForTest replaces the function used by Fatal to exit the program, and
+// returns a function restoring the original.
+func SetExitForTest(f func(code int)) func() {
+	original := exit
+	exit = f
+	return func() {
+		exit = original
+	}
+}
+
+// DerivedHandlersForTest returns the number of derived handlers cached for the
+// last handler added to ctx with WithHandler.
+func DerivedHandlersForTest(ctx context.Context) int {
+	info := ctx.Value(ctxKey{}).(*ctxInfo)
+	n := 0
+	info.handlers[len(info.handlers)-1].derived.Range(func(_, _ any) bool {
+		n++
+		return true
+	})
+	return n
+}
@@ -2,4 +2,7 @@ module github.com/jellevandenhooff/slogctx
 
 go 1.19
 
-require golang.org/x/exp v0.0.0-20230129154200-a960b3787bd2
+require (
+	github.com/google/uuid v1.6.0
+	golang.org/x/exp v0.0.0-20230129154200-a960b3787bd2
+)
@@ -2,6 +2,9 @@ package slogctx
 
 import (
 	"context"
+	"regexp"
+	"runtime"
+	"sync"
 
 	"golang.org/x/exp/slices"
 	"golang.org/x/exp/slog"
@@ -11,11 +14,94 @@ import (
 type ctxKey struct{}
 
 // ctxInfo is the info stored in the context for CtxHandler.
+//
+// ctxInfo is a persistent structure: the attributes of a ctxInfo are the
+// attributes of its parent followed by its own attrs, so that WithAttrs only
+// stores the new attributes. All other fields are copied from the parent.
 type ctxInfo struct {
-	attrs []slog.Attr
+	parent *ctxInfo
+	attrs  []slog.Attr
+	// cache holds values stored with AttrSegment.Store for attrs. It is nil
+	// if attrs contains attributes created by Lazy or LazyAttrs.
+	cache *sync.Map
+
+	// depth is the number of ctxInfos with attributes in the chain ending at
+	// this ctxInfo.
+	depth int
+	// flat caches the attributes of the chain. It is only allocated for chains
+	// deeper than flattenDepth, and shared by copies of the ctxInfo.
+	flat *flatAttrs
+
+	// key is the Key set with Key.Set whose attribute is attrs, or nil for
+	// attributes added with WithAttrs. keyValue is its value.
+	key      any
+	keyValue any
 
 	hasLevel bool
 	level    slog.Level
+
+	stack bool
+
+	spanID       string
+	parentSpanID string
+
+	traceDepth int
+
+	clock Clock
+	ids   IDGenerator
+
+	replaceAttr []func(groups []string, a slog.Attr) slog.Attr
+
+	// handlers are the handlers added with WithHandler. If redirect is set,
+	// records are not passed to the inner handler of the ctxHandler.
+	handlers []*handlerTarget
+	redirect bool
+}
+
+// flattenDepth is the chain depth beyond which ctxInfo caches its flattened
+// attributes.
+const flattenDepth = 4
+
+// flatAttrs is a lazily computed flattened attribute list.
+type flatAttrs struct {
+	once  sync.Once
+	attrs []slog.Attr
+}
+
+// cloneInfo returns a copy of the ctxInfo stored in ctx, or an empty ctxInfo
+// if there is none.
+func cloneInfo(ctx context.Context) ctxInfo {
+	if info, ok := ctx.Value(ctxKey{}).(*ctxInfo); ok {
+		return *info
+	}
+	return ctxInfo{}
+}
+
+// addAttrs adds the attributes of info and its parents to r, oldest first.
+func (info *ctxInfo) addAttrs(r *slog.Record) {
+	if info.flat != nil {
+		info.flat.once.Do(info.flatten)
+		r.AddAttrs(info.flat.attrs...)
+		return
+	}
+	if info.parent != nil {
+		info.parent.addAttrs(r)
+	}
+	r.AddAttrs(info.attrs...)
+}
+
+// appendAttrs appends the attributes of info and its parents to dst, oldest
+// first.
+func (info *ctxInfo) appendAttrs(dst []slog.Attr) []slog.Attr {
+	if info.parent != nil {
+		dst = info.parent.appendAttrs(dst)
+	}
+	return append(dst, info.attrs...)
+}
+
+// flatten fills info.flat.
+func (info *ctxInfo) flatten() {
+	info.flat.attrs = info.appendAttrs(nil)
 }
 
 // pendingGroup is a work-in-progress slog.Group attribute.
@@ -27,14 +113,92 @@ type pendingGroup struct {
 	attrs []slog.Attr
 }
 
+// CtxHandlerOptions are options for the handler returned by
+// WrapWithCtxHandler. A zero CtxHandlerOptions consists entirely of default
+// values.
+type CtxHandlerOptions struct {
+	// StackLevel reports the minimum record level at which the handler adds a
+	// (StackKey, Stack) attribute with the call stack of the log statement.
+	// If StackLevel is nil, stacks are only added for contexts created with
+	// WithStackTrace. Set StackLevel to slog.LevelError to capture a stack
+	// for every error.
+	StackLevel slog.Leveler
+
+	// StackDepth is the maximum number of frames in a stack. If StackDepth is
+	// zero, DefaultStackDepth is used.
+	StackDepth int
+
+	// StackFilter reports whether a frame should be included in a stack.
+	// Frames are only considered starting at the log statement. If
+	// StackFilter is nil, DefaultStackFilter is used.
+	StackFilter func(frame runtime.Frame) bool
+
+	// KeyPattern and AllowedKeys restrict the keys of attributes: those of
+	// records, of the context, of WithAttrs and WithGroup calls on the
+	// handler, and of the attributes in their groups. A key is valid if it
+	// matches KeyPattern or is one of AllowedKeys. Empty keys, and the keys
+	// of the attributes this package adds, like SpanIDKey, StackKey, and
+	// slog.ErrorKey, are always valid. If both are nil, all keys are valid.
+	// Keys in the values of LogValuers are not checked.
+	KeyPattern  *regexp.Regexp
+	AllowedKeys []string
+
+	// InvalidKeys is the action for invalid keys. Warnings for the
+	// attributes of WithAttrs calls on the handler have no call site.
+	InvalidKeys KeyAction
+
+	// RewriteKey returns the replacement of an invalid key for KeyRewrite.
+	// If RewriteKey is nil, SnakeCase is used. Replacements are not checked.
+	RewriteKey func(key string) string
+}
+
+// Wrap wraps a slog.Handler with support for WithAttrs and WithMinimumLevel
+// using the given options.
+func (opts CtxHandlerOptions) Wrap(inner slog.Handler) slog.Handler {
+	return newCtxHandler(inner, &handlerOptions{CtxHandlerOptions: opts, keys: newKeyPolicy(opts)}, nil, nil)
+}
+
+// handlerOptions are the options of a ctxHandler and the state derived from
+// them, shared by the handlers derived from it.
+type handlerOptions struct {
+	CtxHandlerOptions
+
+	keys *keyPolicy // nil if keys are not restricted
+}
+
 // ctxHandler wraps a slog.Handler with support for WithAttrs and
 // WithMinimumLevel.
 type ctxHandler struct {
 	inner slog.Handler
+	opts  *handlerOptions
 
 	// groups is a set of pending slog.Group attributes. Each element will
 	// become a slog.Group nested in the previous group.
 	groups []pendingGroup
+	// groupAttrs holds the attributes of all groups, outermost first, each
+	// followed by a slot for the group nested in it. It is the static part
+	// of the attributes groupRecord builds for each record.
+	groupAttrs []slog.Attr
+
+	// handlerAttrs are the attributes passed to WithAttrs on this handler
+	// and its parents before any group, replayed on handlers added to the
+	// context with WithHandler.
+	handlerAttrs [][]slog.Attr
+
+	// ctxAttrsInner is inner if it implements ContextAttrsHandler.
+	ctxAttrsInner ContextAttrsHandler
+}
+
+func newCtxHandler(inner slog.Handler, opts *handlerOptions, groups []pendingGroup, handlerAttrs [][]slog.Attr) *ctxHandler {
+	ctxAttrsInner, _ := inner.(ContextAttrsHandler)
+	return &ctxHandler{
+		inner:         inner,
+		opts:          opts,
+		groups:        groups,
+		groupAttrs:    flattenGroups(groups),
+		handlerAttrs:  handlerAttrs,
+		ctxAttrsInner: ctxAttrsInner,
+	}
 }
 
 // WrapWithCtxHandler wraps a slog.Handler with support for WithAttrs
@@ -42,56 +206,214 @@ type ctxHandler struct {
 //
 // Use WrapDefaultLoggerWithCtxHandler to wrap the handler used by slog.Default.
 func WrapWithCtxHandler(inner slog.Handler) slog.Handler {
-	return &ctxHandler{inner: inner}
+	return CtxHandlerOptions{}.Wrap(inner)
 }
 
 // Enabled implements Handler. It considers a level added to the context with
-// WithMinimumLevel.
+// WithMinimumLevel, and handlers added with WithHandler.
 func (h *ctxHandler) Enabled(ctx context.Context, level slog.Level) bool {
 	if ctx != nil {
-		if info, ok := ctx.Value(ctxKey{}).(*ctxInfo); ok && info.hasLevel {
-			return level >= info.level
+		if info, ok := ctx.Value(ctxKey{}).(*ctxInfo); ok {
+			if info.hasLevel {
+				return level >= info.level
+			}
+			if info.handlers != nil {
+				return info.targetsEnabled(ctx, level, h.inner)
+			}
 		}
 	}
 	return h.inner.Enabled(ctx, level)
 }
 
 // Handle implements Handler. It adds attributes added to the context with
-// WithAttrs.
+// WithAttrs, the span IDs added with Start, and a stack if requested by the
+// options or WithStackTrace. It sets the time of the record with the clock set
+// with WithClock. It resolves attributes created by Lazy and LazyAttrs,
+// applies the functions added with WithReplaceAttr, and enforces the key
+// options. If the inner handler implements ContextAttrsHandler, the attributes
+// from the context are passed separately. Records are also, or instead, passed
+// to handlers added to the context with WithHandler.
 func (h *ctxHandler) Handle(r slog.Record) error {
+	var info *ctxInfo
+	if r.Context != nil {
+		info, _ = r.Context.Value(ctxKey{}).(*ctxInfo)
+	}
+	if info != nil && info.clock != nil {
+		r.Time = info.clock.Now()
+	}
+
+	var stack Stack
+	if (info != nil && info.stack) || (h.opts.StackLevel != nil && r.Level >= h.opts.StackLevel.Level()) {
+		stack = h.captureStack(r.PC)
+	}
+
+	if info != nil && info.replaceAttr != nil {
+		// Apply the functions before nesting the record's attributes in the
+		// pending groups, so that they do not apply to the attributes added
+		// with WithAttrs in the groups.
+		r = replaceRecord(resolveLazy(r), info.replaceAttr, h.groupNames())
+	}
 	if h.groups != nil {
-		last := h.groups[len(h.groups)-1]
-		attrs := make([]slog.Attr, len(last.attrs)+r.NumAttrs())
-		copy(attrs, last.attrs)
-		i := len(last.attrs)
-		r.Attrs(func(a slog.Attr) {
-			attrs[i] = a
-			i++
-		})
-		attr := slog.Group(last.name, attrs...)
-		for i := len(h.groups) - 2; i >= 0; i-- {
-			cur := h.groups[i]
-			attrs := make([]slog.Attr, len(cur.attrs)+1)
-			copy(attrs, cur.attrs)
-			attrs[len(cur.attrs)] = attr
-			attr = slog.Group(cur.name, attrs...)
+		r = h.groupRecord(r)
+	}
+
+	var warn func(key string)
+	if h.opts.keys != nil {
+		ctx, pc := r.Context, r.PC
+		warn = func(key string) { h.warnKey(ctx, pc, key) }
+	}
+
+	if info != nil && info.handlers != nil {
+		return h.handleTargets(r, info, stack, warn)
+	}
+
+	if h.ctxAttrsInner != nil {
+		r = resolveLazy(r)
+		ctxAttrs := ContextAttrs{info: info, extra: contextExtra(info, stack)}
+		if info != nil && info.replaceAttr != nil {
+			ctxAttrs = replaceContextAttrs(ctxAttrs, info.replaceAttr)
+		}
+		if h.opts.keys != nil {
+			r = h.opts.keys.record(r, warn)
+			ctxAttrs = h.opts.keys.contextAttrs(ctxAttrs, warn)
 		}
-		r = slog.NewRecord(r.Time, r.Level, r.Message, r.PC, r.Context)
-		r.AddAttrs(attr)
+		return h.ctxAttrsInner.HandleContextAttrs(r, ctxAttrs)
 	}
 
-	if r.Context != nil {
-		if info, ok := r.Context.Value(ctxKey{}).(*ctxInfo); ok {
-			r.AddAttrs(info.attrs...)
+	if info != nil && info.replaceAttr != nil {
+		ctxAttrs := replaceContextAttrs(ContextAttrs{info: info, extra: contextExtra(info, stack)}, info.replaceAttr)
+		ctxAttrs.Attrs(func(a slog.Attr) {
+			r.AddAttrs(a)
+		})
+		return h.handleInner(r, warn)
+	}
+	if info != nil {
+		info.addAttrs(&r)
+		if info.spanID != "" {
+			r.AddAttrs(slog.String(SpanIDKey, info.spanID))
+		}
+		if info.parentSpanID != "" {
+			r.AddAttrs(slog.String(ParentSpanIDKey, info.parentSpanID))
 		}
 	}
+	if stack != nil {
+		r.AddAttrs(slog.Any(StackKey, stack))
+	}
+	return h.handleInner(resolveLazy(r), warn)
+}
+
+// handleInner passes r, with all attributes added, to the inner handler after
+// enforcing the key options.
+func (h *ctxHandler) handleInner(r slog.Record, warn func(key string)) error {
+	if h.opts.keys != nil {
+		r = h.opts.keys.record(r, warn)
+	}
 	return h.inner.Handle(r)
 }
 
+// HandleContextAttrs implements ContextAttrsHandler, so that a ctxHandler
+// wrapped in another ctxHandler, for example after WrapWithCtxHandler is
+// called on a handler containing one, adds the attributes from the context
+// once. It handles r and attrs as prepared by the outer ctxHandler, applying
+// only the groups and key options of h.
+func (h *ctxHandler) HandleContextAttrs(r slog.Record, attrs ContextAttrs) error {
+	if h.groups != nil {
+		r = h.groupRecord(r)
+	}
+	if h.opts.keys != nil {
+		ctx, pc := r.Context, r.PC
+		warn := func(key string) { h.warnKey(ctx, pc, key) }
+		r = h.opts.keys.record(r, warn)
+		attrs = h.opts.keys.contextAttrs(attrs, warn)
+	}
+	return handleContextAttrs(h.inner, r, attrs)
+}
+
+// contextExtra returns the attributes a ctxHandler adds to a record after the
+// attributes from the context: the span IDs of info, and stack.
+func contextExtra(info *ctxInfo, stack Stack) []slog.Attr {
+	var extra []slog.Attr
+	if info != nil {
+		if info.spanID != "" {
+			extra = append(extra, slog.String(SpanIDKey, info.spanID))
+		}
+		if info.parentSpanID != "" {
+			extra = append(extra, slog.String(ParentSpanIDKey, info.parentSpanID))
+		}
+	}
+	if stack != nil {
+		extra = append(extra, slog.Any(StackKey, stack))
+	}
+	return extra
+}
+
+// groupNames returns the names of the pending groups of h, outermost first,
+// or nil if there are none.
+func (h *ctxHandler) groupNames() []string {
+	if h.groups == nil {
+		return nil
+	}
+	names := make([]string, len(h.groups))
+	for i, g := range h.groups {
+		names[i] = g.name
+	}
+	return names
+}
+
+// flattenGroups returns the groupAttrs of a ctxHandler with pending groups
+// groups, or nil if there are none.
+func flattenGroups(groups []pendingGroup) []slog.Attr {
+	if groups == nil {
+		return nil
+	}
+	var attrs []slog.Attr
+	for i, g := range groups {
+		attrs = append(attrs, g.attrs...)
+		if i < len(groups)-1 {
+			attrs = append(attrs, slog.Attr{}) // the nested group
+		}
+	}
+	return attrs
+}
+
+// groupRecord returns a copy of r with the attributes of r nested in
+// h.groups. It copies the precomputed h.groupAttrs and the attributes of r
+// into a single new slice, and fills in the nested groups. The slice cannot
+// be reused, as the inner handler may retain the record, so grouped loggers
+// allocate once per record.
+func (h *ctxHandler) groupRecord(r slog.Record) slog.Record {
+	buf := make([]slog.Attr, len(h.groupAttrs)+r.NumAttrs())
+	n := copy(buf, h.groupAttrs)
+	r.Attrs(func(a slog.Attr) {
+		buf[n] = a
+		n++
+	})
+
+	// Build the groups from the innermost one, whose attributes end buf.
+	last := len(h.groups) - 1
+	start := len(h.groupAttrs) - len(h.groups[last].attrs)
+	attr := slog.Group(h.groups[last].name, buf[start:n]...)
+	for i := last - 1; i >= 0; i-- {
+		slot := start - 1
+		buf[slot] = attr
+		start = slot - len(h.groups[i].attrs)
+		attr = slog.Group(h.groups[i].name, buf[start:slot+1]...)
+	}
+
+	grouped := slog.NewRecord(r.Time, r.Level, r.Message, r.PC, r.Context)
+	grouped.AddAttrs(attr)
+	return grouped
+}
+
 // WithAttrs implements Handler. It forwards directly to the original handler if h.groups is nil.
 func (h *ctxHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
 	if h.groups == nil {
-		return &ctxHandler{inner: h.inner.WithAttrs(attrs), groups: nil}
+		// Attributes in pending groups are checked in Handle.
+		if h.opts.keys != nil {
+			attrs, _ = h.opts.keys.attrs(attrs, func(key string) { h.warnKey(nil, 0, key) })
+		}
+		n := len(h.handlerAttrs)
+		return newCtxHandler(h.inner.WithAttrs(attrs), h.opts, nil, append(h.handlerAttrs[:n:n], attrs))
 	} else {
 		cur := h.groups[len(h.groups)-1]
 		newAttrs := make([]slog.Attr, len(cur.attrs)+len(attrs))
@@ -99,16 +421,29 @@ func (h *ctxHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
 		copy(newAttrs[len(cur.attrs):], attrs)
 		newGroups := slices.Clone(h.groups)
 		newGroups[len(newGroups)-1].attrs = newAttrs
-		return &ctxHandler{inner: h.inner, groups: newGroups}
+		return newCtxHandler(h.inner, h.opts, newGroups, h.handlerAttrs)
 	}
 }
 
-// WithGroup implements Handler.
+// WithGroup implements Handler. Groups stay pending in the ctxHandler, so that
+// the attributes from the context are not in them; records logged with a group
+// are rebuilt with one allocation each.
 func (h *ctxHandler) WithGroup(name string) slog.Handler {
 	newGroups := make([]pendingGroup, len(h.groups)+1)
 	copy(newGroups, h.groups)
 	newGroups[len(newGroups)-1].name = name
-	return &ctxHandler{inner: h.inner, groups: newGroups}
+	return newCtxHandler(h.inner, h.opts, newGroups, h.handlerAttrs)
+}
+
+// Flush flushes the inner handler if it implements Flusher.
+func (h *ctxHandler) Flush() error {
+	return flushHandler(h.inner)
+}
+
+// Close closes the inner handler if it implements Closer, and otherwise
+// flushes it if it implements Flusher.
+func (h *ctxHandler) Close() error {
+	return closeHandler(h.inner)
 }
 
 // WithAttrs attaches the given attributes (as in slog.Logger.With) to the
@@ -117,37 +452,74 @@ func (h *ctxHandler) WithGroup(name string) slog.Handler {
 // Requires a slog.Handler wrapped with WrapWithCtxHandler.
 func WithAttrs(ctx context.Context, args ...any) context.Context {
 	newAttrs := argsToAttrs(args)
-	var newInfo ctxInfo
-	if info, ok := ctx.Value(ctxKey{}).(*ctxInfo); ok {
-		newInfo.attrs = make([]slog.Attr, len(info.attrs)+len(newAttrs))
-		copy(newInfo.attrs, info.attrs)
-		copy(newInfo.attrs[len(info.attrs):], newAttrs)
-		newInfo.hasLevel = info.hasLevel
-		newInfo.level = info.level
-	} else {
-		newInfo.attrs = newAttrs
+	info, _ := ctx.Value(ctxKey{}).(*ctxInfo)
+	if len(newAttrs) == 0 {
+		var newInfo ctxInfo
+		if info != nil {
+			newInfo = *info
+		}
+		return context.WithValue(ctx, ctxKey{}, &newInfo)
 	}
+	newInfo := info.appendSegment(newAttrs)
 	return context.WithValue(ctx, ctxKey{}, &newInfo)
 }
 
+// appendSegment returns a copy of info, which may be nil, with the segment
+// attrs appended to its attributes.
+func (info *ctxInfo) appendSegment(attrs []slog.Attr) ctxInfo {
+	var newInfo ctxInfo
+	if info != nil {
+		newInfo = *info
+		if len(info.attrs) > 0 {
+			newInfo.parent = info
+		}
+	}
+	newInfo.attrs = attrs
+	newInfo.key = nil
+	newInfo.keyValue = nil
+	newInfo.cache = newSegmentCache(attrs)
+	newInfo.depth++
+	newInfo.flat = newFlatAttrs(newInfo.depth)
+	return newInfo
+}
+
+// newSegmentCache returns the cache for the segment attrs, or nil if attrs
+// contains attributes created by Lazy or LazyAttrs.
+func newSegmentCache(attrs []slog.Attr) *sync.Map {
+	if slices.ContainsFunc(attrs, isLazy) {
+		return nil
+	}
+	return &sync.Map{}
+}
+
+// newFlatAttrs returns the flat field of a ctxInfo at the given chain depth.
+func newFlatAttrs(depth int) *flatAttrs {
+	if depth > flattenDepth {
+		return &flatAttrs{}
+	}
+	return nil
+}
+
 // WithMinimumLevel overrides the minimum logging level for all log calls using
 // this context.
 //
 // Requires a slog.Handler wrapped with WrapWithCtxHandler.
 func WithMinimumLevel(ctx context.Context, level slog.Level) context.Context {
-	var newInfo ctxInfo
-	if info, ok := ctx.Value(ctxKey{}).(*ctxInfo); ok {
-		newInfo.attrs = info.attrs
-	}
+	newInfo := cloneInfo(ctx)
 	newInfo.hasLevel = true
 	newInfo.level = level
 	return context.WithValue(ctx, ctxKey{}, &newInfo)
 }
 
 // WrapDefaultLoggerWithCtxHandler wraps the handler used by slog.Default() with
-// WrapWithCtxHandler.
+// WrapWithCtxHandler, unless it is already wrapped, so that the attributes
+// from the context are not added twice.
 func WrapDefaultLoggerWithCtxHandler() {
-	slog.SetDefault(slog.New(WrapWithCtxHandler(slog.Default().Handler())))
+	h := slog.Default().Handler()
+	if _, ok := h.(*ctxHandler); ok {
+		return
+	}
+	slog.SetDefault(slog.New(WrapWithCtxHandler(h)))
 }
 
 // copied/modified from golang.org/x/exp/slog/record.go:
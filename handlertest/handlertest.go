@@ -0,0 +1,287 @@
+// Package handlertest checks that slog handlers behave the same when wrapped
+// with slogctx.WrapWithCtxHandler.
+//
+// Run logs a fixed set of records covering groups, attributes added with
+// WithAttrs, LogValuers, source locations, and levels, both to a handler
+// wrapped with slogctx.WrapWithCtxHandler and to the same handler unwrapped,
+// and compares the output. It also checks that attributes from the context
+// are added to the top level of each record after its other attributes, as
+// if they were part of the record. Usage:
+//
+//	func TestMyHandler(t *testing.T) {
+//		handlertest.Run(t, func(w io.Writer) slog.Handler {
+//			return NewMyHandler(w)
+//		})
+//	}
+package handlertest
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/jellevandenhooff/slogctx"
+	"golang.org/x/exp/slog"
+)
+
+// op is a WithAttrs or WithGroup call on a handler.
+type op struct {
+	attrs   []slog.Attr
+	group   string
+	isGroup bool
+}
+
+func withAttrs(attrs ...slog.Attr) op { return op{attrs: attrs} }
+
+func withGroup(name string) op { return op{group: name, isGroup: true} }
+
+// testValuer is a LogValuer resolving to a group.
+type testValuer struct{}
+
+func (testValuer) LogValue() slog.Value {
+	return slog.GroupValue(slog.String("resolved", "yes"), slog.Int("n", 1))
+}
+
+// testCase is a record logged to a handler after applying ops, with ctxAttrs
+// added to the context with slogctx.WithAttrs.
+type testCase struct {
+	name     string
+	ops      []op
+	ctxAttrs []slog.Attr
+	lazy     bool // add ctxAttrs with slogctx.LazyAttrs
+	level    slog.Level
+	source   bool
+	attrs    []slog.Attr
+}
+
+var testCases = []testCase{
+	{
+		name:  "plain",
+		attrs: []slog.Attr{slog.String("a", "one"), slog.Int("b", 2)},
+	},
+	{
+		name:     "context attrs",
+		ctxAttrs: []slog.Attr{slog.String("c", "ctx"), slog.Group("cg", slog.Bool("d", true))},
+		attrs:    []slog.Attr{slog.String("a", "one")},
+	},
+	{
+		name:     "lazy context attrs",
+		ctxAttrs: []slog.Attr{slog.String("c", "lazy")},
+		lazy:     true,
+		attrs:    []slog.Attr{slog.String("a", "one")},
+	},
+	{
+		name:     "with attrs",
+		ops:      []op{withAttrs(slog.Int("w1", 1)), withAttrs(slog.Int("w2", 2))},
+		ctxAttrs: []slog.Attr{slog.String("c", "ctx")},
+		attrs:    []slog.Attr{slog.String("a", "one")},
+	},
+	{
+		name:     "group",
+		ops:      []op{withGroup("g")},
+		ctxAttrs: []slog.Attr{slog.String("c", "ctx")},
+		attrs:    []slog.Attr{slog.String("a", "one")},
+	},
+	{
+		name:     "empty group",
+		ops:      []op{withGroup("g")},
+		ctxAttrs: []slog.Attr{slog.String("c", "ctx")},
+	},
+	{
+		name:     "empty nested group",
+		ops:      []op{withGroup("g"), withAttrs(slog.Int("w", 1)), withGroup("h")},
+		ctxAttrs: []slog.Attr{slog.String("c", "ctx")},
+	},
+	{
+		name: "groups and attrs",
+		ops: []op{
+			withAttrs(slog.Int("w1", 1)),
+			withGroup("g1"),
+			withAttrs(slog.Int("w2", 2), slog.Group("wg", slog.Int("w3", 3))),
+			withGroup("g2"),
+			withAttrs(slog.Int("w4", 4)),
+		},
+		ctxAttrs: []slog.Attr{slog.String("c", "ctx")},
+		attrs:    []slog.Attr{slog.String("a", "one"), slog.Group("rg", slog.Int("r", 1))},
+	},
+	{
+		name:     "empty attrs",
+		ops:      []op{withAttrs(), withGroup("g"), withAttrs(slog.Int("w", 1))},
+		ctxAttrs: []slog.Attr{slog.String("c", "ctx"), slog.Group("empty")},
+		attrs:    []slog.Attr{slog.Group("empty"), slog.String("a", "one")},
+	},
+	{
+		name:     "inline group",
+		ops:      []op{withGroup("g")},
+		ctxAttrs: []slog.Attr{slog.Group("", slog.String("c", "ctx"))},
+		attrs:    []slog.Attr{slog.Group("", slog.String("a", "one"))},
+	},
+	{
+		name:     "log valuer",
+		ops:      []op{withAttrs(slog.Any("wv", testValuer{})), withGroup("g")},
+		ctxAttrs: []slog.Attr{slog.Any("cv", testValuer{})},
+		attrs:    []slog.Attr{slog.Any("v", testValuer{})},
+	},
+	{
+		name:     "source",
+		ctxAttrs: []slog.Attr{slog.String("c", "ctx")},
+		source:   true,
+	},
+	{
+		name:     "levels",
+		ctxAttrs: []slog.Attr{slog.String("c", "ctx")},
+		level:    slog.LevelError + 2,
+	},
+	{
+		name:     "duplicate keys",
+		ops:      []op{withAttrs(slog.String("a", "with"))},
+		ctxAttrs: []slog.Attr{slog.String("a", "ctx")},
+		attrs:    []slog.Attr{slog.String("a", "record")},
+	},
+}
+
+// Run checks that handlers created by newHandler behave the same with and
+// without slogctx.WrapWithCtxHandler, and that the wrapped handlers add
+// attributes from the context. Every call to newHandler must return a new
+// handler writing to w. The output of the handlers may depend on the record
+// time, source, level, message, and attributes, but on nothing else.
+func Run(t *testing.T, newHandler func(w io.Writer) slog.Handler) {
+	t.Run("Enabled", func(t *testing.T) {
+		testEnabled(t, newHandler)
+	})
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			testRecord(t, newHandler, tc)
+		})
+	}
+}
+
+func testEnabled(t *testing.T, newHandler func(w io.Writer) slog.Handler) {
+	inner := newHandler(io.Discard)
+	wrapped := slogctx.WrapWithCtxHandler(newHandler(io.Discard))
+	ctx := context.Background()
+	levels := []slog.Level{slogctx.LevelTrace, slog.LevelDebug, slog.LevelInfo, slog.LevelWarn, slog.LevelError, slogctx.LevelFatal}
+
+	for _, level := range levels {
+		if got, want := wrapped.Enabled(ctx, level), inner.Enabled(ctx, level); got != want {
+			t.Errorf("Enabled(%s) = %v for wrapped handler, but %v for handler", level, got, want)
+		}
+		ctxAttrs := slogctx.WithAttrs(ctx, "a", 1)
+		if got, want := wrapped.Enabled(ctxAttrs, level), inner.Enabled(ctx, level); got != want {
+			t.Errorf("Enabled(%s) with context attrs = %v for wrapped handler, but %v for handler", level, got, want)
+		}
+	}
+
+	for _, minimum := range levels {
+		ctxLevel := slogctx.WithMinimumLevel(ctx, minimum)
+		for _, level := range levels {
+			if got, want := wrapped.Enabled(ctxLevel, level), level >= minimum; got != want {
+				t.Errorf("Enabled(%s) with minimum level %s = %v, want %v", level, minimum, got, want)
+			}
+		}
+	}
+}
+
+var testTime = time.Date(2023, 2, 3, 4, 5, 6, 0, time.UTC)
+
+func testRecord(t *testing.T, newHandler func(w io.Writer) slog.Handler, tc testCase) {
+	var pc uintptr
+	if tc.source {
+		var pcs [1]uintptr
+		runtime.Callers(1, pcs[:])
+		pc = pcs[0]
+	}
+	newRecord := func(ctx context.Context, attrs ...slog.Attr) slog.Record {
+		r := slog.NewRecord(testTime, tc.level, tc.name, pc, ctx)
+		r.AddAttrs(attrs...)
+		return r
+	}
+
+	// Without context attrs, the wrapped handler must behave exactly like
+	// the handler.
+	var want, got bytes.Buffer
+	h := applyOps(newHandler(&want), tc.ops)
+	if err := h.Handle(newRecord(nil, tc.attrs...)); err != nil {
+		t.Fatal(err)
+	}
+	wrapped := applyOps(slogctx.WrapWithCtxHandler(newHandler(&got)), tc.ops)
+	if err := wrapped.Handle(newRecord(context.Background(), tc.attrs...)); err != nil {
+		t.Fatal(err)
+	}
+	if got.String() != want.String() {
+		t.Errorf("without context attrs:\ngot  %s\nwant %s", got.String(), want.String())
+	}
+	if len(tc.ctxAttrs) == 0 {
+		return
+	}
+
+	// With context attrs, the wrapped handler must behave like the handler
+	// with the groups and attributes from WithGroup and WithAttrs calls after
+	// the first group part of the record, and the context attrs added at the
+	// end of the record.
+	want.Reset()
+	got.Reset()
+	h, attrs := emulateGroups(newHandler(&want), tc.ops, tc.attrs)
+	attrs = append(attrs, tc.ctxAttrs...)
+	if err := h.Handle(newRecord(nil, attrs...)); err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	if tc.lazy {
+		ctx = slogctx.LazyAttrs(ctx, func() []any {
+			args := make([]any, len(tc.ctxAttrs))
+			for i, a := range tc.ctxAttrs {
+				args[i] = a
+			}
+			return args
+		})
+	} else {
+		for _, a := range tc.ctxAttrs {
+			ctx = slogctx.WithAttrs(ctx, a)
+		}
+	}
+	if err := wrapped.Handle(newRecord(ctx, tc.attrs...)); err != nil {
+		t.Fatal(err)
+	}
+	if got.String() != want.String() {
+		t.Errorf("with context attrs:\ngot  %s\nwant %s", got.String(), want.String())
+	}
+}
+
+// applyOps calls WithAttrs and WithGroup on h as listed in ops.
+func applyOps(h slog.Handler, ops []op) slog.Handler {
+	for _, op := range ops {
+		if op.isGroup {
+			h = h.WithGroup(op.group)
+		} else {
+			h = h.WithAttrs(op.attrs)
+		}
+	}
+	return h
+}
+
+// emulateGroups applies the ops before the first WithGroup to h, and returns
+// attrs nested in groups for the remaining ops.
+func emulateGroups(h slog.Handler, ops []op, attrs []slog.Attr) (slog.Handler, []slog.Attr) {
+	i := 0
+	for ; i < len(ops) && !ops[i].isGroup; i++ {
+		h = h.WithAttrs(ops[i].attrs)
+	}
+	return h, nestGroups(ops[i:], attrs)
+}
+
+// nestGroups returns attrs nested in the groups of ops, with the attributes
+// of WithAttrs calls preceding the attributes in their group.
+func nestGroups(ops []op, attrs []slog.Attr) []slog.Attr {
+	if len(ops) == 0 {
+		return attrs
+	}
+	if !ops[0].isGroup {
+		return append(append([]slog.Attr(nil), ops[0].attrs...), nestGroups(ops[1:], attrs)...)
+	}
+	return []slog.Attr{slog.Group(ops[0].group, nestGroups(ops[1:], attrs)...)}
+}
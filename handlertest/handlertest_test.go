@@ -0,0 +1,30 @@
+package handlertest_test
+
+import (
+	"io"
+	"testing"
+
+	"github.com/jellevandenhooff/slogctx"
+	"github.com/jellevandenhooff/slogctx/handlertest"
+	"golang.org/x/exp/slog"
+)
+
+func TestSlogHandlers(t *testing.T) {
+	opts := slog.HandlerOptions{AddSource: true, Level: slogctx.LevelTrace}
+	t.Run("Text", func(t *testing.T) {
+		handlertest.Run(t, func(w io.Writer) slog.Handler { return opts.NewTextHandler(w) })
+	})
+	t.Run("JSON", func(t *testing.T) {
+		handlertest.Run(t, func(w io.Writer) slog.Handler { return opts.NewJSONHandler(w) })
+	})
+}
+
+func TestSlogctxHandlers(t *testing.T) {
+	opts := slog.HandlerOptions{AddSource: true}
+	t.Run("Text", func(t *testing.T) {
+		handlertest.Run(t, func(w io.Writer) slog.Handler { return slogctx.NewTextHandler(w, opts) })
+	})
+	t.Run("JSON", func(t *testing.T) {
+		handlertest.Run(t, func(w io.Writer) slog.Handler { return slogctx.NewJSONHandler(w, opts) })
+	})
+}
@@ -0,0 +1,255 @@
+package slogctx
+
+import (
+	"context"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unicode"
+
+	"golang.org/x/exp/slog"
+)
+
+// KeyAction is the action a handler wrapped with CtxHandlerOptions.Wrap takes
+// for attributes with keys rejected by CtxHandlerOptions.KeyPattern and
+// CtxHandlerOptions.AllowedKeys.
+type KeyAction int
+
+const (
+	// KeyWarn keeps invalid keys, and logs a warning record at
+	// slog.LevelWarn the first time a key is used at a call site.
+	KeyWarn KeyAction = iota
+	// KeyRewrite replaces invalid keys with the result of
+	// CtxHandlerOptions.RewriteKey.
+	KeyRewrite
+	// KeyDrop removes attributes with invalid keys.
+	KeyDrop
+)
+
+// InvalidKeyMessage is the message of the warning records logged for invalid
+// keys with KeyWarn. The key is in the attribute with key InvalidKeyKey.
+const (
+	InvalidKeyMessage = "invalid log attribute key"
+	InvalidKeyKey     = "key"
+)
+
+// maxKeyCache is the maximum number of keys whose validity a keyPolicy
+// caches, and the maximum number of warnings it logs, so that dynamic keys
+// cannot grow its memory without bound.
+const maxKeyCache = 1024
+
+// ownKeys are the keys of the attributes this package adds. They are always
+// valid, so that restricting keys does not break spans, traces, and stacks.
+var ownKeys = map[string]bool{
+	SpanIDKey:       true,
+	ParentSpanIDKey: true,
+	DurationKey:     true,
+	TraceDepthKey:   true,
+	StackKey:        true,
+	DroppedKey:      true,
+	slog.ErrorKey:   true,
+}
+
+// keyPolicy enforces the key options of CtxHandlerOptions. It is shared by a
+// handler and the handlers derived from it.
+type keyPolicy struct {
+	pattern *regexp.Regexp
+	allowed map[string]bool
+	action  KeyAction
+	rewrite func(key string) string
+
+	valid      sync.Map // key to bool
+	validCount atomic.Int32
+
+	warned      sync.Map // keySite to struct{}
+	warnedCount atomic.Int32
+}
+
+// keySite is a key used at a call site.
+type keySite struct {
+	key string
+	pc  uintptr
+}
+
+// newKeyPolicy returns the keyPolicy for opts, or nil if opts does not
+// restrict keys.
+func newKeyPolicy(opts CtxHandlerOptions) *keyPolicy {
+	if opts.KeyPattern == nil && opts.AllowedKeys == nil {
+		return nil
+	}
+	p := &keyPolicy{
+		pattern: opts.KeyPattern,
+		allowed: make(map[string]bool, len(opts.AllowedKeys)),
+		action:  opts.InvalidKeys,
+		rewrite: opts.RewriteKey,
+	}
+	for _, key := range opts.AllowedKeys {
+		p.allowed[key] = true
+	}
+	if p.rewrite == nil {
+		p.rewrite = SnakeCase
+	}
+	return p
+}
+
+// isValid reports whether key is valid. Empty keys, which inline groups, and
+// ownKeys are always valid.
+func (p *keyPolicy) isValid(key string) bool {
+	if key == "" || p.allowed[key] || ownKeys[key] {
+		return true
+	}
+	if p.pattern == nil {
+		return false
+	}
+	if v, ok := p.valid.Load(key); ok {
+		return v.(bool)
+	}
+	valid := p.pattern.MatchString(key)
+	if p.validCount.Add(1) <= maxKeyCache {
+		p.valid.Store(key, valid)
+	}
+	return valid
+}
+
+// shouldWarn reports whether to log a warning for key at the call site pc,
+// which it does once.
+func (p *keyPolicy) shouldWarn(key string, pc uintptr) bool {
+	site := keySite{key: key, pc: pc}
+	if _, ok := p.warned.Load(site); ok {
+		return false
+	}
+	if p.warnedCount.Load() >= maxKeyCache {
+		return false
+	}
+	if _, loaded := p.warned.LoadOrStore(site, struct{}{}); loaded {
+		return false
+	}
+	p.warnedCount.Add(1)
+	return true
+}
+
+// attrs returns attrs with the policy applied to their keys and the keys in
+// their groups, and whether any attribute changed. It does not modify attrs.
+// It calls warn for each invalid key if the action is KeyWarn.
+func (p *keyPolicy) attrs(attrs []slog.Attr, warn func(key string)) ([]slog.Attr, bool) {
+	var out []slog.Attr // nil until an attribute changes
+	for i, a := range attrs {
+		b, keep, changed := p.attr(a, warn)
+		if changed && out == nil {
+			out = make([]slog.Attr, i, len(attrs))
+			copy(out, attrs[:i])
+		}
+		if out != nil && keep {
+			out = append(out, b)
+		}
+	}
+	if out == nil {
+		return attrs, false
+	}
+	return out, true
+}
+
+// attr returns a with the policy applied, whether to keep it, and whether it
+// changed. Keys in the values of LogValuers are not checked.
+func (p *keyPolicy) attr(a slog.Attr, warn func(key string)) (slog.Attr, bool, bool) {
+	changed := false
+	if !p.isValid(a.Key) {
+		switch p.action {
+		case KeyDrop:
+			return slog.Attr{}, false, true
+		case KeyRewrite:
+			a.Key = p.rewrite(a.Key)
+			changed = true
+		default:
+			warn(a.Key)
+		}
+	}
+	if a.Value.Kind() == slog.KindGroup {
+		if group, ok := p.attrs(a.Value.Group(), warn); ok {
+			a.Value = slog.GroupValue(group...)
+			changed = true
+		}
+	}
+	return a, true, changed
+}
+
+// record returns r with the policy applied to its attributes.
+func (p *keyPolicy) record(r slog.Record, warn func(key string)) slog.Record {
+	attrs := make([]slog.Attr, 0, r.NumAttrs())
+	r.Attrs(func(a slog.Attr) {
+		attrs = append(attrs, a)
+	})
+	attrs, changed := p.attrs(attrs, warn)
+	if !changed {
+		return r
+	}
+	r = slog.NewRecord(r.Time, r.Level, r.Message, r.PC, r.Context)
+	r.AddAttrs(attrs...)
+	return r
+}
+
+// contextAttrs returns c with the policy applied to its attributes. Segments
+// that change are no longer cached.
+func (p *keyPolicy) contextAttrs(c ContextAttrs, warn func(key string)) ContextAttrs {
+	var segs []AttrSegment
+	c.Range(func(seg AttrSegment) {
+		if attrs, changed := p.attrs(seg.attrs, warn); changed {
+			seg = AttrSegment{attrs: attrs}
+		}
+		segs = append(segs, seg)
+	})
+	if segs == nil {
+		segs = []AttrSegment{}
+	}
+	return ContextAttrs{segs: segs}
+}
+
+// warnKey logs a warning record for the invalid key used at the call site pc
+// to the inner handler, if it is the first use of the key at the call site.
+func (h *ctxHandler) warnKey(ctx context.Context, pc uintptr, key string) {
+	if !h.opts.keys.shouldWarn(key, pc) || !h.inner.Enabled(ctx, slog.LevelWarn) {
+		return
+	}
+	now := time.Now()
+	if ctx != nil {
+		now = clockFrom(ctx).Now()
+	}
+	r := slog.NewRecord(now, slog.LevelWarn, InvalidKeyMessage, pc, ctx)
+	r.AddAttrs(slog.String(InvalidKeyKey, key))
+	// The warning is best effort; the record with the key is handled anyway.
+	_ = h.inner.Handle(r)
+}
+
+// SnakeCase converts key to snake_case: it lowercases letters, separates
+// words, including words in camelCase and acronyms like "ID", with
+// underscores, and replaces other characters with underscores. For example,
+// it converts "requestID", "RequestId", "request-id", and "request.id" to
+// "request_id", and "HTTPStatus" to "http_status". It is the default
+// CtxHandlerOptions.RewriteKey.
+func SnakeCase(key string) string {
+	runes := []rune(key)
+	var b strings.Builder
+	b.Grow(len(key) + 4)
+	pendingSep := false
+	for i, c := range runes {
+		if !unicode.IsLetter(c) && !unicode.IsDigit(c) {
+			pendingSep = b.Len() > 0
+			continue
+		}
+		if unicode.IsUpper(c) && i > 0 {
+			prev := runes[i-1]
+			nextLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if unicode.IsLower(prev) || unicode.IsDigit(prev) || (unicode.IsUpper(prev) && nextLower) {
+				pendingSep = b.Len() > 0
+			}
+		}
+		if pendingSep {
+			b.WriteByte('_')
+			pendingSep = false
+		}
+		b.WriteRune(unicode.ToLower(c))
+	}
+	return b.String()
+}
@@ -0,0 +1,125 @@
+package slogctx_test
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/jellevandenhooff/slogctx"
+	"github.com/jellevandenhooff/slogctx/slogctxtest"
+	"golang.org/x/exp/slog"
+)
+
+var snakeCaseRE = regexp.MustCompile(`^[a-z][a-z0-9_]*$`)
+
+func TestKeyRewrite(t *testing.T) {
+	for _, ctxAttrs := range []bool{false, true} {
+		var buf bytes.Buffer
+		var inner slog.Handler
+		if ctxAttrs {
+			inner = slogctx.NewTextHandler(&buf, slog.HandlerOptions{})
+		} else {
+			inner = slog.HandlerOptions{}.NewTextHandler(&buf)
+		}
+		opts := slogctx.CtxHandlerOptions{
+			KeyPattern:  snakeCaseRE,
+			InvalidKeys: slogctx.KeyRewrite,
+		}
+		logger := slog.New(opts.Wrap(inner)).With("userName", "gopher")
+
+		ctx := slogctx.WithAttrs(context.Background(), "requestID", 1, "valid_key", 2)
+		ctx = slogctx.LazyAttrs(ctx, func() []any { return []any{"lazyKey", 3} })
+		logger.WithGroup("HTTP").WithContext(ctx).Info("hi", "statusCode", 200, slog.Group("Req", slog.String("Method", "GET")))
+		logger.WithContext(ctx).Info("again")
+
+		got := timeAttrRE.ReplaceAllString(buf.String(), "")
+		checkLogOutput(t, got, `level=INFO msg=hi user_name=gopher http.status_code=200 http.req.method=GET request_id=1 valid_key=2 lazy_key=3~`+
+			`level=INFO msg=again user_name=gopher request_id=1 valid_key=2 lazy_key=3`)
+	}
+}
+
+func TestKeyDrop(t *testing.T) {
+	var buf bytes.Buffer
+	opts := slogctx.CtxHandlerOptions{
+		AllowedKeys: []string{"request_id", "status", "group"},
+		InvalidKeys: slogctx.KeyDrop,
+	}
+	logger := slogctx.NewLogger(slog.New(opts.Wrap(slog.HandlerOptions{}.NewTextHandler(&buf))))
+
+	ctx := slogctx.WithAttrs(context.Background(), "request_id", 1, "requestID", 2)
+	logger.Info(ctx, "hi", "status", 200, "Status", 201, slog.Group("group", slog.Int("a", 1), slog.Int("status", 2)))
+
+	got := timeAttrRE.ReplaceAllString(buf.String(), "")
+	checkLogOutput(t, got, `level=INFO msg=hi status=200 group.status=2 request_id=1`)
+}
+
+func TestKeyWarn(t *testing.T) {
+	var buf bytes.Buffer
+	opts := slogctx.CtxHandlerOptions{KeyPattern: snakeCaseRE}
+	logger := slogctx.NewLogger(slog.New(opts.Wrap(slog.HandlerOptions{AddSource: true}.NewTextHandler(&buf))))
+	ctx := slogctx.WithAttrs(context.Background(), "requestID", 1)
+
+	for i := 0; i < 2; i++ {
+		logger.Info(ctx, "first", "badKey", i)
+	}
+	logger.Info(ctx, "second", "badKey", 2)
+
+	got := timeAttrRE.ReplaceAllString(buf.String(), "")
+	src := `source=.*/keys_test.go:\d+`
+	want := []string{
+		`level=WARN ` + src + ` msg="invalid log attribute key" key=badKey`,
+		`level=WARN ` + src + ` msg="invalid log attribute key" key=requestID`,
+		`level=INFO ` + src + ` msg=first badKey=0 requestID=1`,
+		`level=INFO ` + src + ` msg=first badKey=1 requestID=1`,
+		`level=WARN ` + src + ` msg="invalid log attribute key" key=badKey`,
+		`level=WARN ` + src + ` msg="invalid log attribute key" key=requestID`,
+		`level=INFO ` + src + ` msg=second badKey=2 requestID=1`,
+	}
+	checkLogOutput(t, got, strings.Join(want, "~"))
+}
+
+func TestKeyPolicyOwnKeys(t *testing.T) {
+	for _, action := range []slogctx.KeyAction{slogctx.KeyWarn, slogctx.KeyRewrite, slogctx.KeyDrop} {
+		var buf bytes.Buffer
+		opts := slogctx.CtxHandlerOptions{KeyPattern: snakeCaseRE, InvalidKeys: action}
+		logger := slogctx.NewLogger(slog.New(opts.Wrap(slog.HandlerOptions{}.NewTextHandler(&buf))))
+
+		ctx := slogctx.WithIDGenerator(context.Background(), &slogctxtest.FakeIDGenerator{})
+		outerCtx, endOuter := logger.Start(ctx, "outer")
+		_, endInner := logger.Start(outerCtx, "inner")
+		endInner(os.ErrClosed)
+		endOuter(nil)
+		logger.Info(slogctx.WithStackTrace(outerCtx), "stack")
+
+		got := timeAttrRE.ReplaceAllString(buf.String(), "")
+		checkLogOutput(t, got, strings.Join([]string{
+			`level=INFO msg="outer started" spanID=0000000000000001`,
+			`level=INFO msg="inner started" spanID=0000000000000002 parentSpanID=0000000000000001`,
+			`level=ERROR msg="inner failed" duration=.* err="file already closed" spanID=0000000000000002 parentSpanID=0000000000000001`,
+			`level=INFO msg="outer finished" duration=.* spanID=0000000000000001`,
+			`level=INFO msg=stack spanID=0000000000000001 stack=.*`,
+		}, "~"))
+	}
+}
+
+func TestSnakeCase(t *testing.T) {
+	for _, tc := range []struct {
+		key, want string
+	}{
+		{"request_id", "request_id"},
+		{"requestID", "request_id"},
+		{"RequestId", "request_id"},
+		{"request-id", "request_id"},
+		{"request.id", "request_id"},
+		{"HTTPStatus", "http_status"},
+		{"statusCode2xx", "status_code2xx"},
+		{"_leading__and trailing_", "leading_and_trailing"},
+	} {
+		if got := slogctx.SnakeCase(tc.key); got != tc.want {
+			t.Errorf("SnakeCase(%q) = %q, want %q", tc.key, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package slogctx
+
+import (
+	"context"
+
+	"golang.org/x/exp/slog"
+)
+
+// lazyValue is a slog.LogValuer computing its value with a function.
+type lazyValue func() any
+
+// LogValue implements slog.LogValuer.
+func (f lazyValue) LogValue() slog.Value {
+	return slog.AnyValue(f())
+}
+
+// lazyAttrs is a function added to the context by LazyAttrs. It is stored in
+// ctxInfo.attrs as the value of an attribute with an empty key.
+type lazyAttrs func() []any
+
+// Lazy returns an Attr whose value is computed by calling f. Use Lazy for
+// values that are expensive to compute, such as serialized request bodies.
+//
+// A ctxHandler calls f only for records that pass Enabled, and exactly once
+// per record, even if the inner handler inspects the value several times.
+// Other handlers resolve the value like any slog.LogValuer.
+func Lazy(key string, f func() any) slog.Attr {
+	return slog.Any(key, lazyValue(f))
+}
+
+// LazyAttrs attaches attributes computed by calling f (as in
+// slog.Logger.With) to the context.
+//
+// f is called only for records that pass Enabled, and exactly once per record.
+//
+// Requires a slog.Handler wrapped with WrapWithCtxHandler.
+func LazyAttrs(ctx context.Context, f func() []any) context.Context {
+	return WithAttrs(ctx, slog.Any("", lazyAttrs(f)))
+}
+
+// isLazy reports whether a is or contains an attribute created by Lazy or
+// LazyAttrs.
+func isLazy(a slog.Attr) bool {
+	switch a.Value.Kind() {
+	case slog.KindLogValuer:
+		_, ok := a.Value.LogValuer().(lazyValue)
+		return ok
+	case slog.KindAny:
+		_, ok := a.Value.Any().(lazyAttrs)
+		return ok
+	case slog.KindGroup:
+		for _, aa := range a.Value.Group() {
+			if isLazy(aa) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// appendResolvedLazy appends a to attrs, resolving attributes created by Lazy
+// and expanding attributes created by LazyAttrs.
+func appendResolvedLazy(attrs []slog.Attr, a slog.Attr) []slog.Attr {
+	switch a.Value.Kind() {
+	case slog.KindLogValuer:
+		if _, ok := a.Value.LogValuer().(lazyValue); ok {
+			a.Value = a.Value.Resolve()
+		}
+	case slog.KindAny:
+		if f, ok := a.Value.Any().(lazyAttrs); ok {
+			for _, aa := range argsToAttrs(f()) {
+				attrs = appendResolvedLazy(attrs, aa)
+			}
+			return attrs
+		}
+	case slog.KindGroup:
+		if isLazy(a) {
+			var group []slog.Attr
+			for _, aa := range a.Value.Group() {
+				group = appendResolvedLazy(group, aa)
+			}
+			a = slog.Group(a.Key, group...)
+		}
+	}
+	return append(attrs, a)
+}
+
+// resolveLazy returns r with all attributes created by Lazy and LazyAttrs
+// resolved. It returns r unmodified if there are no such attributes.
+func resolveLazy(r slog.Record) slog.Record {
+	found := false
+	r.Attrs(func(a slog.Attr) {
+		if !found && isLazy(a) {
+			found = true
+		}
+	})
+	if !found {
+		return r
+	}
+
+	attrs := make([]slog.Attr, 0, r.NumAttrs())
+	r.Attrs(func(a slog.Attr) {
+		attrs = appendResolvedLazy(attrs, a)
+	})
+	r = slog.NewRecord(r.Time, r.Level, r.Message, r.PC, r.Context)
+	r.AddAttrs(attrs...)
+	return r
+}
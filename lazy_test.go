@@ -0,0 +1,90 @@
+package slogctx_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/jellevandenhooff/slogctx"
+	"golang.org/x/exp/slog"
+)
+
+// resolvingHandler resolves all attributes before forwarding to the inner
+// handler, simulating a handler that inspects values.
+type resolvingHandler struct {
+	slog.Handler
+}
+
+func (h resolvingHandler) Handle(r slog.Record) error {
+	r.Attrs(func(a slog.Attr) {
+		a.Value.Resolve()
+	})
+	return h.Handler.Handle(r)
+}
+
+func TestLazy(t *testing.T) {
+	check := setupTestSlogHandler(t, slog.HandlerOptions{})
+
+	// setup slogctx
+	slog.SetDefault(slog.New(slogctx.WrapWithCtxHandler(resolvingHandler{slog.Default().Handler()})))
+
+	calls := 0
+	expensive := func() any {
+		calls++
+		return calls
+	}
+
+	ctx := context.Background()
+
+	slogctx.Debug(ctx, "ignored", slogctx.Lazy("lazy", expensive))
+	check(``)
+	if calls != 0 {
+		t.Errorf("expected no calls for disabled record, got %d", calls)
+	}
+
+	slogctx.Info(ctx, "hi", slogctx.Lazy("lazy", expensive))
+	check(`level=INFO msg=hi lazy=1`)
+	if calls != 1 {
+		t.Errorf("expected 1 call, got %d", calls)
+	}
+
+	slogctx.Info(ctx, "hi", slog.Group("group", slogctx.Lazy("lazy", expensive)))
+	check(`level=INFO msg=hi group.lazy=2`)
+
+	lazyCtx := slogctx.WithAttrs(ctx, slogctx.Lazy("ctxLazy", expensive))
+	slogctx.Info(lazyCtx, "hi")
+	check(`level=INFO msg=hi ctxLazy=3`)
+	slogctx.Info(lazyCtx, "again")
+	check(`level=INFO msg=again ctxLazy=4`)
+}
+
+func TestLazyAttrs(t *testing.T) {
+	check := setupTestSlogHandler(t, slog.HandlerOptions{})
+
+	// setup slogctx
+	slog.SetDefault(slog.New(slogctx.WrapWithCtxHandler(resolvingHandler{slog.Default().Handler()})))
+
+	calls := 0
+	ctx := context.Background()
+	ctx = slogctx.WithAttrs(ctx, "before", 1)
+	ctx = slogctx.LazyAttrs(ctx, func() []any {
+		calls++
+		return []any{"calls", calls, slog.String("attr", "value")}
+	})
+	ctx = slogctx.WithAttrs(ctx, "after", 2)
+
+	slogctx.Debug(ctx, "ignored")
+	check(``)
+	if calls != 0 {
+		t.Errorf("expected no calls for disabled record, got %d", calls)
+	}
+
+	slogctx.Info(ctx, "hi")
+	check(`level=INFO msg=hi before=1 calls=1 attr=value after=2`)
+
+	slog.Default().WithGroup("group").WithContext(ctx).Info("grouped", "a", "b")
+	check(`level=INFO msg=grouped group.a=b before=1 calls=2 attr=value after=2`)
+
+	if calls != 2 {
+		t.Errorf("expected 2 calls, got %d", calls)
+	}
+}
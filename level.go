@@ -0,0 +1,54 @@
+package slogctx
+
+import (
+	"fmt"
+
+	"golang.org/x/exp/slog"
+)
+
+// Levels in addition to the levels defined by slog.
+const (
+	// LevelTrace is the level used by Logger.Trace and Trace, below
+	// slog.LevelDebug.
+	LevelTrace slog.Level = slog.LevelDebug - 4
+	// LevelFatal is the level used by Logger.Fatal and Fatal, above
+	// slog.LevelError.
+	LevelFatal slog.Level = slog.LevelError + 4
+)
+
+// LevelString returns a name for the level like slog.Level.String, but names
+// levels below slog.LevelDebug after LevelTrace and levels at or above
+// LevelFatal after LevelFatal. For example, LevelTrace is "TRACE" and
+// LevelFatal+1 is "FATAL+1".
+func LevelString(l slog.Level) string {
+	str := func(base string, val slog.Level) string {
+		if val == 0 {
+			return base
+		}
+		return fmt.Sprintf("%s%+d", base, val)
+	}
+
+	switch {
+	case l < slog.LevelDebug:
+		return str("TRACE", l-LevelTrace)
+	case l >= LevelFatal:
+		return str("FATAL", l-LevelFatal)
+	default:
+		return l.String()
+	}
+}
+
+// ReplaceLevelNames is a function for slog.HandlerOptions.ReplaceAttr that
+// renders the built-in level attribute using LevelString, so that LevelTrace
+// and LevelFatal are output as "TRACE" and "FATAL" by the text and JSON
+// handlers. Usage:
+//
+//	handler := slog.HandlerOptions{ReplaceAttr: slogctx.ReplaceLevelNames}.NewTextHandler(os.Stderr)
+func ReplaceLevelNames(groups []string, a slog.Attr) slog.Attr {
+	if len(groups) == 0 && a.Key == slog.LevelKey {
+		if l, ok := a.Value.Any().(slog.Level); ok {
+			a.Value = slog.StringValue(LevelString(l))
+		}
+	}
+	return a
+}
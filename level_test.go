@@ -0,0 +1,106 @@
+package slogctx_test
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/jellevandenhooff/slogctx"
+	"golang.org/x/exp/slog"
+)
+
+func TestLevelString(t *testing.T) {
+	for _, tc := range []struct {
+		level slog.Level
+		want  string
+	}{
+		{slogctx.LevelTrace - 1, "TRACE-1"},
+		{slogctx.LevelTrace, "TRACE"},
+		{slogctx.LevelTrace + 1, "TRACE+1"},
+		{slog.LevelDebug, "DEBUG"},
+		{slog.LevelError, "ERROR"},
+		{slog.LevelError + 1, "ERROR+1"},
+		{slogctx.LevelFatal, "FATAL"},
+		{slogctx.LevelFatal + 2, "FATAL+2"},
+	} {
+		if got := slogctx.LevelString(tc.level); got != tc.want {
+			t.Errorf("LevelString(%d) = %q, want %q", tc.level, got, tc.want)
+		}
+	}
+}
+
+func TestTraceAndFatalLevels(t *testing.T) {
+	check := setupTestSlogHandler(t, slog.HandlerOptions{
+		ReplaceAttr: slogctx.ReplaceLevelNames,
+	})
+
+	// setup slogctx
+	slogctx.WrapDefaultLoggerWithCtxHandler()
+
+	var exitCodes []int
+	t.Cleanup(slogctx.SetExitForTest(func(code int) {
+		exitCodes = append(exitCodes, code)
+	}))
+
+	ctx := context.Background()
+	logger := slogctx.Default()
+
+	logger.Trace(ctx, "hidden")
+	check(``)
+
+	traceCtx := slogctx.WithMinimumLevel(ctx, slogctx.LevelTrace)
+	logger.Trace(traceCtx, "hi")
+	check(`level=TRACE msg=hi`)
+
+	slogctx.Log(traceCtx, slogctx.LevelTrace, "hi")
+	check(`level=TRACE msg=hi`)
+
+	slogctx.Trace(ctx, "hidden")
+	check(``)
+
+	slogctx.Trace(traceCtx, "top-level")
+	check(`level=TRACE msg=top-level`)
+
+	fatalCtx := slogctx.WithMinimumLevel(ctx, slogctx.LevelFatal)
+	logger.Error(fatalCtx, "hidden", nil)
+	check(``)
+
+	logger.Fatal(fatalCtx, "bye", os.ErrClosed)
+	check(`level=FATAL msg=bye err="file already closed"`)
+
+	slogctx.Fatal(ctx, "bye", nil)
+	check(`level=FATAL msg=bye`)
+
+	if len(exitCodes) != 2 || exitCodes[0] != 1 || exitCodes[1] != 1 {
+		t.Errorf("expected two exits with code 1, got %v", exitCodes)
+	}
+}
+
+type flushCountingHandler struct {
+	slog.Handler
+	flushes int
+}
+
+func (h *flushCountingHandler) Flush() error {
+	h.flushes++
+	return nil
+}
+
+func TestFatalFlushes(t *testing.T) {
+	t.Cleanup(slogctx.SetExitForTest(func(code int) {}))
+
+	var buf bytes.Buffer
+	inner := &flushCountingHandler{Handler: slog.HandlerOptions{ReplaceAttr: slogctx.ReplaceLevelNames}.NewJSONHandler(&buf)}
+	logger := slogctx.NewLogger(slog.New(slogctx.WrapWithCtxHandler(inner)))
+
+	logger.Fatal(context.Background(), "bye", nil)
+
+	if inner.flushes != 1 {
+		t.Errorf("expected 1 flush, got %d", inner.flushes)
+	}
+	if !strings.Contains(buf.String(), `"level":"FATAL"`) {
+		t.Errorf("expected FATAL level in JSON output, got %s", buf.String())
+	}
+}
@@ -0,0 +1,88 @@
+package slogctx
+
+import (
+	"context"
+
+	"golang.org/x/exp/slog"
+)
+
+// Flusher is implemented by handlers that buffer records. Flush writes all
+// buffered records before returning.
+//
+// Handlers wrapping another handler, like the ctxHandler and AsyncHandler,
+// implement Flusher by forwarding to their inner handler, so that the
+// handler of a logger can be flushed regardless of how it is built.
+type Flusher interface {
+	Flush() error
+}
+
+// Closer is implemented by handlers that hold resources, like a background
+// goroutine. Close flushes the handler and releases its resources. Handlers
+// must remain usable after Close, though they may then handle records
+// synchronously.
+//
+// Handlers wrapping another handler implement Closer by forwarding to their
+// inner handler.
+type Closer interface {
+	Close() error
+}
+
+// flushHandler flushes h if it implements Flusher.
+func flushHandler(h slog.Handler) error {
+	if f, ok := h.(Flusher); ok {
+		return f.Flush()
+	}
+	return nil
+}
+
+// closeHandler closes h if it implements Closer, and otherwise flushes it if
+// it implements Flusher.
+func closeHandler(h slog.Handler) error {
+	if c, ok := h.(Closer); ok {
+		return c.Close()
+	}
+	return flushHandler(h)
+}
+
+// flushContext flushes h and the handlers added to ctx with WithHandler. It
+// returns the first error.
+func flushContext(ctx context.Context, h slog.Handler) error {
+	err := flushHandler(h)
+	if info, ok := ctx.Value(ctxKey{}).(*ctxInfo); ok {
+		for _, t := range info.handlers {
+			if terr := flushHandler(t.h); err == nil {
+				err = terr
+			}
+		}
+	}
+	return err
+}
+
+// Shutdown flushes the handler of the default logger, and the handlers added
+// to ctx with WithHandler, typically before the program exits. It returns
+// ctx.Err() if ctx is done before the handlers are flushed, and otherwise the
+// first error returned by a handler. Usage:
+//
+//	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+//	defer cancel()
+//	if err := slogctx.Shutdown(ctx); err != nil {
+//		fmt.Fprintln(os.Stderr, "flushing logs:", err)
+//	}
+//
+// Shutdown flushes rather than closes the handlers, so that records logged
+// after Shutdown, for example by goroutines still running, are handled as
+// before. If ctx is done first, the flush continues in the background after
+// Shutdown returns, and may still write records.
+func Shutdown(ctx context.Context) error {
+	h := slog.Default().Handler()
+	done := make(chan error, 1)
+	go func() {
+		done <- flushContext(ctx, h)
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,85 @@
+package slogctx_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jellevandenhooff/slogctx"
+	"golang.org/x/exp/slog"
+)
+
+func TestFlushChain(t *testing.T) {
+	var buf bytes.Buffer
+	async := slogctx.NewAsyncHandler(slog.HandlerOptions{}.NewTextHandler(&buf), slogctx.AsyncOptions{})
+	defer async.Close()
+	logger := slog.New(slogctx.WrapWithCtxHandler(async)).With("a", 1).WithGroup("g")
+
+	logger.Info("hi", "b", 2)
+	flusher, ok := logger.Handler().(slogctx.Flusher)
+	if !ok {
+		t.Fatalf("expected %T to implement Flusher", logger.Handler())
+	}
+	if err := flusher.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	checkLogOutput(t, timeAttrRE.ReplaceAllString(buf.String(), ""), `level=INFO msg=hi a=1 g.b=2`)
+}
+
+func TestShutdown(t *testing.T) {
+	var buf, job bytes.Buffer
+	async := slogctx.NewAsyncHandler(slog.HandlerOptions{}.NewTextHandler(&buf), slogctx.AsyncOptions{})
+	defer async.Close()
+	jobAsync := slogctx.NewAsyncHandler(slog.HandlerOptions{}.NewTextHandler(&job), slogctx.AsyncOptions{})
+	defer jobAsync.Close()
+	original := slog.Default()
+	slog.SetDefault(slog.New(slogctx.WrapWithCtxHandler(async)))
+	t.Cleanup(func() {
+		slog.SetDefault(original)
+	})
+
+	// Shutdown also flushes handlers added to the context.
+	ctx := slogctx.WithHandler(context.Background(), jobAsync, slogctx.HandlerTee)
+	slogctx.Info(ctx, "before")
+	if err := slogctx.Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+	checkLogOutput(t, timeAttrRE.ReplaceAllString(buf.String(), ""), `level=INFO msg=before`)
+	checkLogOutput(t, timeAttrRE.ReplaceAllString(job.String(), ""), `level=INFO msg=before`)
+
+	// After Shutdown, the handlers still work.
+	buf.Reset()
+	slogctx.Info(ctx, "after")
+	if err := slogctx.Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+	checkLogOutput(t, timeAttrRE.ReplaceAllString(buf.String(), ""), `level=INFO msg=after`)
+}
+
+func TestShutdownDeadline(t *testing.T) {
+	var buf bytes.Buffer
+	gated := newGatedHandler(slog.HandlerOptions{}.NewTextHandler(&buf))
+	async := slogctx.NewAsyncHandler(gated, slogctx.AsyncOptions{})
+	original := slog.Default()
+	slog.SetDefault(slog.New(slogctx.WrapWithCtxHandler(async)))
+	t.Cleanup(func() {
+		slog.SetDefault(original)
+	})
+
+	slogctx.Info(context.Background(), "stuck")
+	<-gated.started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := slogctx.Shutdown(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected %v, got %v", context.DeadlineExceeded, err)
+	}
+
+	close(gated.release)
+	if err := async.Close(); err != nil {
+		t.Fatal(err)
+	}
+	checkLogOutput(t, timeAttrRE.ReplaceAllString(buf.String(), ""), `level=INFO msg=stuck`)
+}
@@ -2,6 +2,7 @@ package slogctx
 
 import (
 	"context"
+	"os"
 
 	"golang.org/x/exp/slog"
 )
@@ -35,6 +36,11 @@ func (l *Logger) With(args ...any) *Logger {
 	}
 }
 
+// Trace logs at LevelTrace.
+func (l *Logger) Trace(ctx context.Context, msg string, args ...any) {
+	l.Inner.WithContext(ctx).LogDepth(1, LevelTrace, msg, args...)
+}
+
 // Debug logs at LevelDebug.
 func (l *Logger) Debug(ctx context.Context, msg string, args ...any) {
 	l.Inner.WithContext(ctx).LogDepth(1, slog.LevelDebug, msg, args...)
@@ -60,11 +66,42 @@ func (l *Logger) Error(ctx context.Context, msg string, err error, args ...any)
 	l.Inner.WithContext(ctx).LogDepth(1, slog.LevelError, msg, args...)
 }
 
+// Fatal logs at LevelFatal, flushes the logger's handler and the handlers
+// added to ctx with WithHandler if they buffer records, and exits the program
+// with status 1.
+// If err is non-nil, Fatal appends Any(ErrorKey, err)
+// to the list of attributes.
+func (l *Logger) Fatal(ctx context.Context, msg string, err error, args ...any) {
+	if err != nil {
+		args = append(args, slog.Any(slog.ErrorKey, err))
+	}
+	l.Inner.WithContext(ctx).LogDepth(1, LevelFatal, msg, args...)
+	flushContext(ctx, l.Inner.Handler())
+	exit(1)
+}
+
 // Log emits a log record, like slog.Logger.Log.
 func (l *Logger) Log(ctx context.Context, level slog.Level, msg string, args ...any) {
 	l.Inner.WithContext(ctx).LogDepth(1, level, msg, args...)
 }
 
+// LogAttrsDepth emits a log record with the given attributes, like
+// slog.Logger.LogAttrsDepth. The calldepth argument skips stack frames when
+// reporting the source: 0 reports the caller of LogAttrsDepth. Use it in
+// helpers that log on behalf of their callers.
+func (l *Logger) LogAttrsDepth(ctx context.Context, calldepth int, level slog.Level, msg string, attrs ...slog.Attr) {
+	l.Inner.WithContext(ctx).LogAttrsDepth(calldepth+1, level, msg, attrs...)
+}
+
+// exit is os.Exit, replaced in tests.
+var exit = os.Exit
+
+// Trace calls Logger.WithContext(ctx).Log with LevelTrace on the default
+// logger.
+func Trace(ctx context.Context, msg string, args ...any) {
+	slog.Default().WithContext(ctx).LogDepth(1, LevelTrace, msg, args...)
+}
+
 // Debug calls Logger.WithContext(ctx).Debug on the default logger.
 func Debug(ctx context.Context, msg string, args ...any) {
 	slog.Default().WithContext(ctx).LogDepth(1, slog.LevelDebug, msg, args...)
@@ -87,3 +124,19 @@ func Error(ctx context.Context, msg string, err error, args ...any) {
 	}
 	slog.Default().WithContext(ctx).LogDepth(1, slog.LevelError, msg, args...)
 }
+
+// Fatal calls Logger.WithContext(ctx).Fatal on the default logger.
+func Fatal(ctx context.Context, msg string, err error, args ...any) {
+	if err != nil {
+		args = append(args, slog.Any(slog.ErrorKey, err))
+	}
+	logger := slog.Default()
+	logger.WithContext(ctx).LogDepth(1, LevelFatal, msg, args...)
+	flushContext(ctx, logger.Handler())
+	exit(1)
+}
+
+// Log calls Logger.WithContext(ctx).Log on the default logger.
+func Log(ctx context.Context, level slog.Level, msg string, args ...any) {
+	slog.Default().WithContext(ctx).LogDepth(1, level, msg, args...)
+}
@@ -0,0 +1,532 @@
+package slogctx
+
+import (
+	"context"
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"runtime"
+	"strconv"
+	"sync"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/exp/slices"
+	"golang.org/x/exp/slog"
+)
+
+// NewTextHandler returns a handler that writes records to w in the format of
+// slog.TextHandler.
+//
+// The handler implements ContextAttrsHandler. When wrapped with
+// WrapWithCtxHandler, it encodes the attributes added to a context by each
+// WithAttrs call once, and reuses the encoding for all records logged using
+// the context.
+func NewTextHandler(w io.Writer, opts slog.HandlerOptions) slog.Handler {
+	return &outputHandler{shared: &outputShared{json: false, opts: opts, w: w}}
+}
+
+// NewJSONHandler returns a handler that writes records to w in the format of
+// slog.JSONHandler.
+//
+// The handler implements ContextAttrsHandler. When wrapped with
+// WrapWithCtxHandler, it encodes the attributes added to a context by each
+// WithAttrs call once, and reuses the encoding for all records logged using
+// the context.
+func NewJSONHandler(w io.Writer, opts slog.HandlerOptions) slog.Handler {
+	return &outputHandler{shared: &outputShared{json: true, opts: opts, w: w}}
+}
+
+// outputShared is the state shared by an outputHandler and all handlers
+// derived from it. Its address is the key for cached segment encodings.
+type outputShared struct {
+	json bool // true => output JSON; false => output text
+	opts slog.HandlerOptions
+	mu   sync.Mutex
+	w    io.Writer
+}
+
+// outputHandler implements NewTextHandler and NewJSONHandler. It mirrors the
+// handlers in slog.
+type outputHandler struct {
+	shared       *outputShared
+	preformatted []byte
+	groupPrefix  string   // for text: prefix of groups opened in preformatting
+	groups       []string // all groups started from WithGroup
+	nOpenGroups  int      // the number of groups opened in preformatted
+}
+
+func (h *outputHandler) clone() *outputHandler {
+	return &outputHandler{
+		shared:       h.shared,
+		preformatted: slices.Clip(h.preformatted),
+		groupPrefix:  h.groupPrefix,
+		groups:       slices.Clip(h.groups),
+		nOpenGroups:  h.nOpenGroups,
+	}
+}
+
+// Enabled implements Handler.
+func (h *outputHandler) Enabled(_ context.Context, level slog.Level) bool {
+	minLevel := slog.LevelInfo
+	if h.shared.opts.Level != nil {
+		minLevel = h.shared.opts.Level.Level()
+	}
+	return level >= minLevel
+}
+
+// WithAttrs implements Handler. It pre-formats the attributes.
+func (h *outputHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	h2 := h.clone()
+	s := h2.newState(h2.preformatted)
+	if len(h2.preformatted) > 0 {
+		s.sep = h.attrSep()
+	}
+	s.prefix = append(s.prefix, h.groupPrefix...)
+	s.groups = append(s.groups, h.groups[:h.nOpenGroups]...)
+	for _, name := range h.groups[h.nOpenGroups:] {
+		s.openGroup(name)
+	}
+	for _, a := range attrs {
+		s.appendAttr(a)
+	}
+	h2.preformatted = s.buf
+	h2.groupPrefix = string(s.prefix)
+	h2.nOpenGroups = len(h2.groups)
+	return h2
+}
+
+// WithGroup implements Handler.
+func (h *outputHandler) WithGroup(name string) slog.Handler {
+	h2 := h.clone()
+	h2.groups = append(h2.groups, name)
+	return h2
+}
+
+// Handle implements Handler.
+func (h *outputHandler) Handle(r slog.Record) error {
+	return h.handle(r, ContextAttrs{})
+}
+
+// HandleContextAttrs implements ContextAttrsHandler. It outputs the
+// attributes from the context after the record's attributes, using cached
+// encodings where possible.
+func (h *outputHandler) HandleContextAttrs(r slog.Record, attrs ContextAttrs) error {
+	return h.handle(r, attrs)
+}
+
+var bufPool = sync.Pool{New: func() any {
+	b := make([]byte, 0, 1024)
+	return &b
+}}
+
+func (h *outputHandler) handle(r slog.Record, ctxAttrs ContextAttrs) error {
+	bufp := bufPool.Get().(*[]byte)
+	s := h.newState((*bufp)[:0])
+
+	json := h.shared.json
+	rep := h.shared.opts.ReplaceAttr
+	if json {
+		s.buf = append(s.buf, '{')
+	}
+	// Built-in attributes. They are not in a group.
+	if !r.Time.IsZero() {
+		val := r.Time.Round(0) // strip monotonic to match Attr behavior
+		if rep == nil {
+			s.appendKey(slog.TimeKey)
+			s.appendTime(val)
+		} else {
+			s.appendAttr(slog.Time(slog.TimeKey, val))
+		}
+	}
+	if rep == nil {
+		s.appendKey(slog.LevelKey)
+		s.appendString(r.Level.String())
+	} else {
+		s.appendAttr(slog.Any(slog.LevelKey, r.Level))
+	}
+	if h.shared.opts.AddSource && r.PC != 0 {
+		frame, _ := runtime.CallersFrames([]uintptr{r.PC}).Next()
+		if frame.File != "" {
+			if rep == nil {
+				s.appendKey(slog.SourceKey)
+				s.appendSource(frame.File, frame.Line)
+			} else {
+				s.appendAttr(slog.String(slog.SourceKey, frame.File+":"+strconv.Itoa(frame.Line)))
+			}
+		}
+	}
+	if rep == nil {
+		s.appendKey(slog.MessageKey)
+		s.appendString(r.Message)
+	} else {
+		s.appendAttr(slog.String(slog.MessageKey, r.Message))
+	}
+
+	// Attributes from WithAttrs and the record, in groups started from
+	// WithGroup.
+	if len(h.preformatted) > 0 {
+		s.buf = append(s.buf, s.sep...)
+		s.buf = append(s.buf, h.preformatted...)
+		s.sep = h.attrSep()
+	}
+	s.prefix = append(s.prefix, h.groupPrefix...)
+	s.groups = append(s.groups, h.groups[:h.nOpenGroups]...)
+	for _, name := range h.groups[h.nOpenGroups:] {
+		s.openGroup(name)
+	}
+	r.Attrs(func(a slog.Attr) {
+		s.appendAttr(a)
+	})
+	if json {
+		for range h.groups {
+			s.buf = append(s.buf, '}')
+		}
+	}
+	s.sep = h.attrSep()
+	s.prefix = s.prefix[:0]
+	s.groups = s.groups[:0]
+
+	// Attributes from the context, outside of any groups.
+	ctxAttrs.Range(s.appendSegment)
+
+	if json {
+		s.buf = append(s.buf, '}')
+	}
+	s.buf = append(s.buf, '\n')
+
+	h.shared.mu.Lock()
+	_, err := h.shared.w.Write(s.buf)
+	h.shared.mu.Unlock()
+
+	*bufp = s.buf
+	bufPool.Put(bufp)
+	return err
+}
+
+// attrSep returns the separator between attributes.
+func (h *outputHandler) attrSep() string {
+	if h.shared.json {
+		return ","
+	}
+	return " "
+}
+
+// outputState holds state for encoding a record or a list of attributes.
+type outputState struct {
+	h      *outputHandler
+	buf    []byte
+	sep    string   // separator to write before next key
+	prefix []byte   // for text: key prefix
+	groups []string // active groups, for ReplaceAttr
+}
+
+func (h *outputHandler) newState(buf []byte) *outputState {
+	return &outputState{h: h, buf: buf}
+}
+
+// appendSegment appends the attributes of seg, using and filling the cached
+// encoding of the segment if it is cacheable.
+func (s *outputState) appendSegment(seg AttrSegment) {
+	var encoded []byte
+	if cached, ok := seg.Load(s.h.shared); ok {
+		encoded = cached.([]byte)
+	} else {
+		segState := s.h.newState(nil)
+		for _, a := range seg.Attrs() {
+			segState.appendAttr(a)
+		}
+		encoded = segState.buf
+		if seg.cacheable() {
+			seg.Store(s.h.shared, encoded)
+		}
+	}
+	if len(encoded) > 0 {
+		s.buf = append(s.buf, s.sep...)
+		s.buf = append(s.buf, encoded...)
+		s.sep = s.h.attrSep()
+	}
+}
+
+// openGroup starts a new group of attributes with the given name.
+func (s *outputState) openGroup(name string) {
+	if s.h.shared.json {
+		s.appendKey(name)
+		s.buf = append(s.buf, '{')
+		s.sep = ""
+	} else {
+		s.prefix = append(s.prefix, name...)
+		s.prefix = append(s.prefix, '.')
+	}
+	s.groups = append(s.groups, name)
+}
+
+// closeGroup ends the group with the given name.
+func (s *outputState) closeGroup(name string) {
+	if s.h.shared.json {
+		s.buf = append(s.buf, '}')
+	} else {
+		s.prefix = s.prefix[:len(s.prefix)-len(name)-1]
+	}
+	s.sep = s.h.attrSep()
+	s.groups = s.groups[:len(s.groups)-1]
+}
+
+// appendAttr appends the Attr's key and value, handling replacement and empty
+// keys.
+func (s *outputState) appendAttr(a slog.Attr) {
+	if a.Key == "" {
+		return
+	}
+	v := a.Value.Resolve()
+	if rep := s.h.shared.opts.ReplaceAttr; rep != nil && v.Kind() != slog.KindGroup {
+		var gs []string
+		if len(s.groups) > 0 {
+			gs = s.groups
+		}
+		a = rep(gs, slog.Attr{Key: a.Key, Value: v})
+		if a.Key == "" {
+			return
+		}
+		v = a.Value.Resolve()
+	}
+	if v.Kind() == slog.KindGroup {
+		s.openGroup(a.Key)
+		for _, aa := range v.Group() {
+			s.appendAttr(aa)
+		}
+		s.closeGroup(a.Key)
+	} else {
+		s.appendKey(a.Key)
+		s.appendValue(v)
+	}
+}
+
+func (s *outputState) appendKey(key string) {
+	s.buf = append(s.buf, s.sep...)
+	if len(s.prefix) > 0 {
+		s.appendString(string(s.prefix) + key)
+	} else {
+		s.appendString(key)
+	}
+	if s.h.shared.json {
+		s.buf = append(s.buf, ':')
+	} else {
+		s.buf = append(s.buf, '=')
+	}
+	s.sep = s.h.attrSep()
+}
+
+func (s *outputState) appendString(str string) {
+	if s.h.shared.json {
+		s.buf = append(s.buf, '"')
+		s.buf = appendEscapedJSONString(s.buf, str)
+		s.buf = append(s.buf, '"')
+	} else if needsQuoting(str) {
+		s.buf = strconv.AppendQuote(s.buf, str)
+	} else {
+		s.buf = append(s.buf, str...)
+	}
+}
+
+func (s *outputState) appendSource(file string, line int) {
+	if s.h.shared.json || needsQuoting(file) {
+		s.appendString(file + ":" + strconv.Itoa(line))
+	} else {
+		s.buf = append(s.buf, file...)
+		s.buf = append(s.buf, ':')
+		s.buf = strconv.AppendInt(s.buf, int64(line), 10)
+	}
+}
+
+func (s *outputState) appendTime(t time.Time) {
+	if s.h.shared.json {
+		s.buf = append(s.buf, '"')
+		s.buf = t.AppendFormat(s.buf, time.RFC3339Nano)
+		s.buf = append(s.buf, '"')
+	} else {
+		s.buf = t.AppendFormat(s.buf, "2006-01-02T15:04:05.000Z07:00")
+	}
+}
+
+func (s *outputState) appendValue(v slog.Value) {
+	var err error
+	if s.h.shared.json {
+		err = s.appendJSONValue(v)
+	} else {
+		err = s.appendTextValue(v)
+	}
+	if err != nil {
+		s.appendString(fmt.Sprintf("!ERROR:%v", err))
+	}
+}
+
+func (s *outputState) appendTextValue(v slog.Value) error {
+	switch v.Kind() {
+	case slog.KindString:
+		s.appendString(v.String())
+	case slog.KindTime:
+		s.appendTime(v.Time())
+	case slog.KindAny:
+		if tm, ok := v.Any().(encoding.TextMarshaler); ok {
+			data, err := tm.MarshalText()
+			if err != nil {
+				return err
+			}
+			s.appendString(string(data))
+			return nil
+		}
+		if bs, ok := byteSlice(v.Any()); ok {
+			s.buf = strconv.AppendQuote(s.buf, string(bs))
+			return nil
+		}
+		s.appendString(fmt.Sprint(v.Any()))
+	case slog.KindInt64:
+		s.buf = strconv.AppendInt(s.buf, v.Int64(), 10)
+	case slog.KindUint64:
+		s.buf = strconv.AppendUint(s.buf, v.Uint64(), 10)
+	case slog.KindFloat64:
+		s.buf = strconv.AppendFloat(s.buf, v.Float64(), 'g', -1, 64)
+	case slog.KindBool:
+		s.buf = strconv.AppendBool(s.buf, v.Bool())
+	default:
+		s.buf = append(s.buf, v.String()...)
+	}
+	return nil
+}
+
+func (s *outputState) appendJSONValue(v slog.Value) error {
+	switch v.Kind() {
+	case slog.KindString:
+		s.appendString(v.String())
+	case slog.KindInt64:
+		s.buf = strconv.AppendInt(s.buf, v.Int64(), 10)
+	case slog.KindUint64:
+		s.buf = strconv.AppendUint(s.buf, v.Uint64(), 10)
+	case slog.KindFloat64:
+		f := v.Float64()
+		switch {
+		case math.IsInf(f, 1):
+			s.buf = append(s.buf, `"+Inf"`...)
+		case math.IsInf(f, -1):
+			s.buf = append(s.buf, `"-Inf"`...)
+		case math.IsNaN(f):
+			s.buf = append(s.buf, `"NaN"`...)
+		default:
+			return s.appendJSONMarshal(f)
+		}
+	case slog.KindBool:
+		s.buf = strconv.AppendBool(s.buf, v.Bool())
+	case slog.KindDuration:
+		s.buf = strconv.AppendInt(s.buf, int64(v.Duration()), 10)
+	case slog.KindTime:
+		s.appendTime(v.Time())
+	case slog.KindAny:
+		a := v.Any()
+		if err, ok := a.(error); ok {
+			s.appendString(err.Error())
+		} else {
+			return s.appendJSONMarshal(a)
+		}
+	default:
+		panic(fmt.Sprintf("bad kind: %d", v.Kind()))
+	}
+	return nil
+}
+
+func (s *outputState) appendJSONMarshal(v any) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	s.buf = append(s.buf, b...)
+	return nil
+}
+
+// copied/modified from golang.org/x/exp/slog/text_handler.go and
+// json_handler.go:
+
+func byteSlice(a any) ([]byte, bool) {
+	if bs, ok := a.([]byte); ok {
+		return bs, true
+	}
+	// Like Printf's %s, we allow both the slice type and the byte element type to be named.
+	t := reflect.TypeOf(a)
+	if t != nil && t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8 {
+		return reflect.ValueOf(a).Bytes(), true
+	}
+	return nil, false
+}
+
+func needsQuoting(s string) bool {
+	for i := 0; i < len(s); {
+		b := s[i]
+		if b < utf8.RuneSelf {
+			if b == '"' || b == '=' || unicode.IsSpace(rune(b)) || !unicode.IsPrint(rune(b)) {
+				return true
+			}
+			i++
+			continue
+		}
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if r == utf8.RuneError || unicode.IsSpace(r) || !unicode.IsPrint(r) {
+			return true
+		}
+		i += size
+	}
+	return false
+}
+
+// appendEscapedJSONString escapes s for JSON and appends it to buf. It does
+// not surround the string in quotation marks.
+func appendEscapedJSONString(buf []byte, s string) []byte {
+	const hex = "0123456789abcdef"
+	start := 0
+	for i := 0; i < len(s); {
+		if b := s[i]; b < utf8.RuneSelf {
+			if b >= ' ' && b != '"' && b != '\\' && b != '<' && b != '>' && b != '&' {
+				i++
+				continue
+			}
+			buf = append(buf, s[start:i]...)
+			buf = append(buf, '\\')
+			switch b {
+			case '\\', '"':
+				buf = append(buf, b)
+			case '\n':
+				buf = append(buf, 'n')
+			case '\r':
+				buf = append(buf, 'r')
+			case '\t':
+				buf = append(buf, 't')
+			default:
+				buf = append(buf, 'u', '0', '0', hex[b>>4], hex[b&0xF])
+			}
+			i++
+			start = i
+			continue
+		}
+		c, size := utf8.DecodeRuneInString(s[i:])
+		if c == utf8.RuneError && size == 1 {
+			buf = append(buf, s[start:i]...)
+			buf = append(buf, `\ufffd`...)
+			i += size
+			start = i
+			continue
+		}
+		if c == '\u2028' || c == '\u2029' {
+			buf = append(buf, s[start:i]...)
+			buf = append(buf, `\u202`...)
+			buf = append(buf, hex[c&0xF])
+			i += size
+			start = i
+			continue
+		}
+		i += size
+	}
+	return append(buf, s[start:]...)
+}
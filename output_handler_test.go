@@ -0,0 +1,170 @@
+package slogctx_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"math"
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jellevandenhooff/slogctx"
+	"golang.org/x/exp/slog"
+)
+
+var timeAttrRE = regexp.MustCompile(`"time":"[^"]*",|time=\S+ `)
+
+type countingValuer struct {
+	calls *int
+}
+
+func (v countingValuer) LogValue() slog.Value {
+	*v.calls++
+	return slog.IntValue(*v.calls)
+}
+
+// countingMarshaler counts how often it is encoded.
+type countingMarshaler struct {
+	calls *int
+}
+
+func (m countingMarshaler) MarshalText() ([]byte, error) {
+	*m.calls++
+	return []byte(strconv.Itoa(*m.calls)), nil
+}
+
+// TestOutputHandlerMatchesSlog verifies that NewTextHandler and NewJSONHandler
+// produce the same output as their slog counterparts.
+func TestOutputHandlerMatchesSlog(t *testing.T) {
+	var pcs [1]uintptr
+	runtime.Callers(1, pcs[:])
+	now := time.Date(2022, 1, 29, 15, 10, 0, 123456789, time.UTC)
+
+	derives := map[string]func(h slog.Handler) slog.Handler{
+		"plain": func(h slog.Handler) slog.Handler { return h },
+		"with": func(h slog.Handler) slog.Handler {
+			return h.WithAttrs([]slog.Attr{slog.String("with", "attr")})
+		},
+		"groups": func(h slog.Handler) slog.Handler {
+			return h.WithAttrs([]slog.Attr{slog.Int("a", 1)}).WithGroup("g1").WithAttrs([]slog.Attr{slog.Int("b", 2)}).WithGroup("g2")
+		},
+		"empty-group": func(h slog.Handler) slog.Handler {
+			return h.WithGroup("empty")
+		},
+	}
+	attrs := []slog.Attr{
+		slog.String("str", "hello world"),
+		slog.String("quote", `a "quoted" = string`),
+		slog.String("html", "<a href='x'>&</a>\u2028"),
+		slog.Int("int", -3),
+		slog.Uint64("uint", 7),
+		slog.Float64("float", 1.5),
+		slog.Float64("inf", math.Inf(1)),
+		slog.Bool("bool", true),
+		slog.Duration("dur", 1500*time.Millisecond),
+		slog.Time("time", now),
+		slog.Any("err", errors.New("oops")),
+		slog.Any("level", slog.LevelWarn),
+		slog.Any("bytes", []byte("raw")),
+		slog.Any("map", map[string]int{"x": 1}),
+		slog.Group("group", slog.Int("inner", 1), slog.Group("nested", slog.String("deep", "yes"))),
+		slog.Group("emptygroup"),
+		slog.Any("valuer", slog.StringValue("resolved")),
+	}
+	replace := func(groups []string, a slog.Attr) slog.Attr {
+		if a.Key == "int" {
+			return slog.Attr{}
+		}
+		if len(groups) > 0 {
+			a.Key = groups[len(groups)-1] + "_" + a.Key
+		}
+		return a
+	}
+
+	for _, opts := range []slog.HandlerOptions{
+		{},
+		{AddSource: true},
+		{ReplaceAttr: replace, AddSource: true},
+	} {
+		for name, derive := range derives {
+			for _, json := range []bool{false, true} {
+				var got, want bytes.Buffer
+				var h, slogH slog.Handler
+				if json {
+					h, slogH = slogctx.NewJSONHandler(&got, opts), opts.NewJSONHandler(&want)
+				} else {
+					h, slogH = slogctx.NewTextHandler(&got, opts), opts.NewTextHandler(&want)
+				}
+				h, slogH = derive(h), derive(slogH)
+
+				r := slog.NewRecord(now, slog.LevelInfo, "a message", pcs[0], nil)
+				r.AddAttrs(attrs...)
+				if err := h.Handle(r); err != nil {
+					t.Fatal(err)
+				}
+				if err := slogH.Handle(r); err != nil {
+					t.Fatal(err)
+				}
+
+				if got.String() != want.String() {
+					t.Errorf("%s json=%v replace=%v:\ngot  %s\nwant %s", name, json, opts.ReplaceAttr != nil, got.String(), want.String())
+				}
+			}
+		}
+	}
+}
+
+func TestOutputHandlerContextAttrs(t *testing.T) {
+	for _, json := range []bool{false, true} {
+		var buf bytes.Buffer
+		var inner slog.Handler
+		if json {
+			inner = slogctx.NewJSONHandler(&buf, slog.HandlerOptions{})
+		} else {
+			inner = slogctx.NewTextHandler(&buf, slog.HandlerOptions{})
+		}
+		logger := slogctx.NewLogger(slog.New(slogctx.WrapWithCtxHandler(inner)))
+
+		calls, valuerCalls := 0, 0
+		ctx := context.Background()
+		ctx = slogctx.WithAttrs(ctx, "requestID", 1234, "counted", countingMarshaler{&calls})
+		ctx = slogctx.WithAttrs(ctx, "valuer", countingValuer{&valuerCalls})
+		ctx = slogctx.LazyAttrs(ctx, func() []any { return []any{"lazy", "value"} })
+		ctx, _ = logger.Start(ctx, "span")
+		ctx = slogctx.WithAttrs(ctx, "last", true)
+
+		logger.Info(ctx, "one", "attr", 1)
+		logger.Inner.With("with", "attr").WithGroup("g").WithContext(ctx).Info("two", "attr", 2)
+		logger.Info(ctx, "three")
+
+		if calls != 1 {
+			t.Errorf("json=%v: expected context attrs to be encoded once, got %d calls", json, calls)
+		}
+		if valuerCalls != 4 {
+			t.Errorf("json=%v: expected LogValuer in context attrs to be resolved for each record, got %d calls", json, valuerCalls)
+		}
+
+		var want []string
+		if json {
+			want = []string{
+				`{"level":"INFO","msg":"span started","requestID":1234,"counted":"1","valuer":1,"lazy":"value","spanID":"\w+"}`,
+				`{"level":"INFO","msg":"one","attr":1,"requestID":1234,"counted":"1","valuer":2,"lazy":"value","last":true,"spanID":"\w+"}`,
+				`{"level":"INFO","msg":"two","with":"attr","g":{"attr":2},"requestID":1234,"counted":"1","valuer":3,"lazy":"value","last":true,"spanID":"\w+"}`,
+				`{"level":"INFO","msg":"three","requestID":1234,"counted":"1","valuer":4,"lazy":"value","last":true,"spanID":"\w+"}`,
+			}
+		} else {
+			want = []string{
+				`level=INFO msg="span started" requestID=1234 counted=1 valuer=1 lazy=value spanID=\w+`,
+				`level=INFO msg=one attr=1 requestID=1234 counted=1 valuer=2 lazy=value last=true spanID=\w+`,
+				`level=INFO msg=two with=attr g.attr=2 requestID=1234 counted=1 valuer=3 lazy=value last=true spanID=\w+`,
+				`level=INFO msg=three requestID=1234 counted=1 valuer=4 lazy=value last=true spanID=\w+`,
+			}
+		}
+		got := timeAttrRE.ReplaceAllString(buf.String(), "")
+		checkLogOutput(t, got, strings.Join(want, "~"))
+	}
+}
@@ -0,0 +1,254 @@
+package slogctx
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"regexp"
+	"sync"
+
+	"golang.org/x/exp/slog"
+)
+
+// RedactMode determines how a RedactHandler replaces redacted values.
+type RedactMode int
+
+const (
+	// RedactMask replaces values with RedactedValue.
+	RedactMask RedactMode = iota
+	// RedactHash replaces values with "hmac:" followed by the first 8 bytes,
+	// hex-encoded, of the HMAC-SHA256 of the value, keyed with
+	// RedactOptions.HashKey. Equal values have equal hashes, so they can be
+	// correlated across records without being revealed.
+	RedactHash
+)
+
+// RedactOptions are options for a RedactHandler. A zero RedactOptions
+// consists entirely of default values.
+type RedactOptions struct {
+	// KeyPatterns match the keys of attributes whose values are redacted,
+	// in addition to Secret values. Keys are matched without the names of
+	// their groups. The value of a matching group is redacted as a whole.
+	KeyPatterns []*regexp.Regexp
+
+	// Mode determines how values are replaced. The default mode is
+	// RedactMask.
+	Mode RedactMode
+
+	// HashKey is the key of the HMAC for RedactHash. It must not be empty
+	// for RedactHash.
+	HashKey []byte
+}
+
+// RedactHandler is a handler that redacts Secret values and the values of
+// attributes with keys matching RedactOptions.KeyPatterns, including in
+// groups, before passing records to an inner handler.
+//
+// Wrap the RedactHandler, not its inner handler, with WrapWithCtxHandler, so
+// that it also redacts the attributes from the context:
+//
+//	h := slogctx.WrapWithCtxHandler(slogctx.NewRedactHandler(inner, slogctx.RedactOptions{
+//		KeyPatterns: []*regexp.Regexp{regexp.MustCompile(`(?i)token|password`)},
+//	}))
+type RedactHandler struct {
+	inner slog.Handler
+	r     *redactor
+}
+
+// redactor holds the options of a RedactHandler, shared by all handlers
+// derived from it.
+type redactor struct {
+	patterns []*regexp.Regexp
+	mode     RedactMode
+	hashKey  []byte
+}
+
+// redactedSegment is the redacted form of an AttrSegment, stored in the
+// segment under its redactor.
+type redactedSegment struct {
+	attrs []slog.Attr
+	cache *sync.Map
+}
+
+// NewRedactHandler returns a RedactHandler passing records to inner. It panics
+// if opts.Mode is RedactHash and opts.HashKey is empty.
+func NewRedactHandler(inner slog.Handler, opts RedactOptions) *RedactHandler {
+	if opts.Mode == RedactHash && len(opts.HashKey) == 0 {
+		panic("slogctx: RedactHash requires a HashKey")
+	}
+	return &RedactHandler{
+		inner: inner,
+		r: &redactor{
+			patterns: opts.KeyPatterns,
+			mode:     opts.Mode,
+			hashKey:  opts.HashKey,
+		},
+	}
+}
+
+// Enabled implements Handler.
+func (h *RedactHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.inner.Enabled(ctx, level)
+}
+
+// Handle implements Handler. It redacts the attributes of the record.
+func (h *RedactHandler) Handle(r slog.Record) error {
+	return h.inner.Handle(h.r.record(r, nil))
+}
+
+// HandleContextAttrs implements ContextAttrsHandler. It redacts the attributes
+// of the record and from the context. If the inner handler does not implement
+// ContextAttrsHandler, the attributes from the context are added to the
+// record.
+func (h *RedactHandler) HandleContextAttrs(r slog.Record, attrs ContextAttrs) error {
+	inner, ok := h.inner.(ContextAttrsHandler)
+	if !ok {
+		var ctxAttrs []slog.Attr
+		attrs.Attrs(func(a slog.Attr) {
+			ctxAttrs = append(ctxAttrs, a)
+		})
+		return h.inner.Handle(h.r.record(r, ctxAttrs))
+	}
+
+	var segs []AttrSegment
+	attrs.Range(func(seg AttrSegment) {
+		segs = append(segs, h.r.segment(seg))
+	})
+	if segs == nil {
+		segs = []AttrSegment{}
+	}
+	return inner.HandleContextAttrs(h.r.record(r, nil), ContextAttrs{segs: segs})
+}
+
+// WithAttrs implements Handler. It redacts attrs.
+func (h *RedactHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	redacted, _ := h.r.attrs(attrs)
+	return &RedactHandler{inner: h.inner.WithAttrs(redacted), r: h.r}
+}
+
+// WithGroup implements Handler.
+func (h *RedactHandler) WithGroup(name string) slog.Handler {
+	return &RedactHandler{inner: h.inner.WithGroup(name), r: h.r}
+}
+
+// Flush flushes the inner handler if it implements Flusher.
+func (h *RedactHandler) Flush() error {
+	return flushHandler(h.inner)
+}
+
+// Close closes the inner handler if it implements Closer, and otherwise
+// flushes it if it implements Flusher.
+func (h *RedactHandler) Close() error {
+	return closeHandler(h.inner)
+}
+
+// record returns r with its attributes and extra redacted.
+func (rd *redactor) record(r slog.Record, extra []slog.Attr) slog.Record {
+	attrs := make([]slog.Attr, 0, r.NumAttrs()+len(extra))
+	r.Attrs(func(a slog.Attr) {
+		attrs = append(attrs, a)
+	})
+	attrs = append(attrs, extra...)
+	attrs, changed := rd.attrs(attrs)
+	if !changed && len(extra) == 0 {
+		return r
+	}
+	r = slog.NewRecord(r.Time, r.Level, r.Message, r.PC, r.Context)
+	r.AddAttrs(attrs...)
+	return r
+}
+
+// segment returns seg redacted. The redacted form of a segment shared by many
+// records is computed once and stored in the segment, unless the segment
+// contains LogValuers that must be resolved for each record.
+func (rd *redactor) segment(seg AttrSegment) AttrSegment {
+	if v, ok := seg.Load(rd); ok {
+		rs := v.(*redactedSegment)
+		return AttrSegment{attrs: rs.attrs, cache: rs.cache}
+	}
+	attrs, changed := rd.attrs(seg.attrs)
+	if !seg.cacheable() {
+		return AttrSegment{attrs: attrs}
+	}
+	if !changed {
+		seg.Store(rd, &redactedSegment{attrs: seg.attrs, cache: seg.cache})
+		return seg
+	}
+	rs := &redactedSegment{attrs: attrs}
+	if seg.cache != nil {
+		rs.cache = &sync.Map{}
+	}
+	seg.Store(rd, rs)
+	return AttrSegment{attrs: rs.attrs, cache: rs.cache}
+}
+
+// attrs returns attrs redacted, and whether any attribute changed. It does
+// not modify attrs.
+func (rd *redactor) attrs(attrs []slog.Attr) ([]slog.Attr, bool) {
+	var out []slog.Attr // nil until an attribute changes
+	for i, a := range attrs {
+		b, changed := rd.attr(a)
+		if changed && out == nil {
+			out = make([]slog.Attr, i, len(attrs))
+			copy(out, attrs[:i])
+		}
+		if out != nil {
+			out = append(out, b)
+		}
+	}
+	if out == nil {
+		return attrs, false
+	}
+	return out, true
+}
+
+// attr returns a redacted, and whether it changed. It resolves LogValuers
+// other than Secrets.
+func (rd *redactor) attr(a slog.Attr) (slog.Attr, bool) {
+	v := a.Value
+	changed := false
+	if v.Kind() == slog.KindLogValuer {
+		if s, ok := v.LogValuer().(secret); ok {
+			a.Value = rd.redact(slog.AnyValue(s.secretValue()))
+			return a, true
+		}
+		v = v.Resolve()
+		changed = true
+	}
+	if rd.matchKey(a.Key) {
+		a.Value = rd.redact(v)
+		return a, true
+	}
+	if v.Kind() == slog.KindGroup {
+		if group, ok := rd.attrs(v.Group()); ok {
+			v = slog.GroupValue(group...)
+			changed = true
+		}
+	}
+	a.Value = v
+	return a, changed
+}
+
+// matchKey reports whether key matches one of the key patterns.
+func (rd *redactor) matchKey(key string) bool {
+	if key == "" {
+		return false
+	}
+	for _, re := range rd.patterns {
+		if re.MatchString(key) {
+			return true
+		}
+	}
+	return false
+}
+
+// redact returns the replacement of v.
+func (rd *redactor) redact(v slog.Value) slog.Value {
+	if rd.mode != RedactHash {
+		return slog.StringValue(RedactedValue)
+	}
+	mac := hmac.New(sha256.New, rd.hashKey)
+	mac.Write([]byte(v.Resolve().String()))
+	return slog.StringValue("hmac:" + hex.EncodeToString(mac.Sum(nil)[:8]))
+}
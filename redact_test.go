@@ -0,0 +1,104 @@
+package slogctx_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/jellevandenhooff/slogctx"
+	"golang.org/x/exp/slog"
+)
+
+func TestSecret(t *testing.T) {
+	s := slogctx.NewSecret("hunter2")
+	if s.Value() != "hunter2" {
+		t.Errorf("Value() = %q, want hunter2", s.Value())
+	}
+	for _, format := range []string{"%v", "%s", "%+v", "%#v", "%q", "%x"} {
+		if got := fmt.Sprintf(format, s); got != slogctx.RedactedValue {
+			t.Errorf("Sprintf(%q) = %q, want %q", format, got, slogctx.RedactedValue)
+		}
+	}
+
+	// Secrets are masked by any handler.
+	check := setupTestSlogHandler(t, slog.HandlerOptions{})
+	slog.Info("login", "password", s)
+	check(`level=INFO msg=login password=\[REDACTED\]`)
+}
+
+func TestRedactHandler(t *testing.T) {
+	for _, ctxAttrs := range []bool{false, true} {
+		var buf bytes.Buffer
+		var inner slog.Handler
+		if ctxAttrs {
+			inner = slogctx.NewTextHandler(&buf, slog.HandlerOptions{})
+		} else {
+			inner = slog.HandlerOptions{}.NewTextHandler(&buf)
+		}
+		h := slogctx.WrapWithCtxHandler(slogctx.NewRedactHandler(inner, slogctx.RedactOptions{
+			KeyPatterns: []*regexp.Regexp{regexp.MustCompile(`(?i)token|password`)},
+		}))
+		logger := slogctx.NewLogger(slog.New(h).With("apiToken", "with"))
+
+		n := 1
+		ctx := slogctx.WithAttrs(context.Background(), "user", "gopher", "session", slogctx.NewSecret("abc"), "n", counterValuer{&n})
+		ctx = slogctx.WithAttrs(ctx, slog.Group("auth", slog.String("refresh_token", "xyz"), slog.String("scheme", "bearer")))
+		logger.Info(ctx, "hi", "password", "hunter2", slog.Group("creds", slog.String("user", "u"), slog.String("Password", "p")))
+		n++
+		logger.Info(ctx, "again")
+
+		got := timeAttrRE.ReplaceAllString(buf.String(), "")
+		want := []string{
+			`level=INFO msg=hi apiToken=\[REDACTED\] password=\[REDACTED\] creds.user=u creds.Password=\[REDACTED\] user=gopher session=\[REDACTED\] n=1 auth.refresh_token=\[REDACTED\] auth.scheme=bearer`,
+			`level=INFO msg=again apiToken=\[REDACTED\] user=gopher session=\[REDACTED\] n=2 auth.refresh_token=\[REDACTED\] auth.scheme=bearer`,
+		}
+		checkLogOutput(t, got, strings.Join(want, "~"))
+	}
+}
+
+func TestRedactHandlerHash(t *testing.T) {
+	var buf bytes.Buffer
+	newLogger := func(key string) *slogctx.Logger {
+		h := slogctx.NewRedactHandler(slog.HandlerOptions{}.NewTextHandler(&buf), slogctx.RedactOptions{
+			KeyPatterns: []*regexp.Regexp{regexp.MustCompile(`^token$`)},
+			Mode:        slogctx.RedactHash,
+			HashKey:     []byte(key),
+		})
+		return slogctx.NewLogger(slog.New(slogctx.WrapWithCtxHandler(h)))
+	}
+	ctx := context.Background()
+	logger := newLogger("key1")
+	logger.Info(ctx, "one", "token", "abc", "secret", slogctx.NewSecret("abc"))
+	logger.Info(ctx, "two", "token", "def")
+	newLogger("key2").Info(ctx, "three", "token", "abc")
+
+	var hashes []string
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		for _, field := range strings.Fields(line) {
+			if key, value, ok := strings.Cut(field, "="); ok && (key == "token" || key == "secret") {
+				if !regexp.MustCompile(`^hmac:[0-9a-f]{16}$`).MatchString(value) {
+					t.Errorf("%s=%s is not a hash", key, value)
+				}
+				hashes = append(hashes, value)
+			}
+		}
+	}
+	if len(hashes) != 4 {
+		t.Fatalf("expected 4 hashes, got %v", hashes)
+	}
+	if hashes[0] != hashes[1] {
+		t.Errorf("expected equal hashes for equal values, got %v", hashes)
+	}
+	if hashes[0] == hashes[2] {
+		t.Errorf("expected different hashes for different values, got %v", hashes)
+	}
+	if hashes[0] == hashes[3] {
+		t.Errorf("expected different hashes for different keys, got %v", hashes)
+	}
+	if strings.Contains(buf.String(), "abc") {
+		t.Errorf("secret value in output:\n%s", buf.String())
+	}
+}
@@ -0,0 +1,86 @@
+package slogctx
+
+import (
+	"context"
+
+	"golang.org/x/exp/slog"
+)
+
+// WithReplaceAttr adds fn to the functions rewriting the attributes of all log
+// calls using this context. Like slog.HandlerOptions.ReplaceAttr, fn is called
+// with each non-group attribute, after resolving its value, and the names of
+// its enclosing groups, including groups started with WithGroup; groups is nil
+// for attributes at the top level, like the attributes from the context.
+// Attributes for which fn returns an Attr with an empty key are removed.
+//
+// Functions are applied in the order they are added, to the attributes of the
+// record and the attributes from the context, but not to attributes added with
+// WithAttrs on the handler, or with slog.Logger.With, whether or not a group
+// was started before them. They are applied before
+// CtxHandlerOptions.KeyPattern and CtxHandlerOptions.AllowedKeys. Inner
+// handlers implementing ContextAttrsHandler cannot cache the encoding of the
+// attributes from the context for records using the functions. Usage:
+//
+//	ctx = slogctx.WithReplaceAttr(ctx, func(groups []string, a slog.Attr) slog.Attr {
+//		if a.Key == "body" {
+//			a.Value = slog.StringValue("<omitted>")
+//		}
+//		return a
+//	})
+//
+// Requires a slog.Handler wrapped with WrapWithCtxHandler.
+func WithReplaceAttr(ctx context.Context, fn func(groups []string, a slog.Attr) slog.Attr) context.Context {
+	newInfo := cloneInfo(ctx)
+	n := len(newInfo.replaceAttr)
+	newInfo.replaceAttr = append(newInfo.replaceAttr[:n:n], fn)
+	return context.WithValue(ctx, ctxKey{}, &newInfo)
+}
+
+// replaceRecord returns r, whose attributes are in the groups named groups,
+// with the functions fns applied to its attributes.
+func replaceRecord(r slog.Record, fns []func([]string, slog.Attr) slog.Attr, groups []string) slog.Record {
+	attrs := make([]slog.Attr, 0, r.NumAttrs())
+	r.Attrs(func(a slog.Attr) {
+		attrs = append(attrs, a)
+	})
+	r = slog.NewRecord(r.Time, r.Level, r.Message, r.PC, r.Context)
+	r.AddAttrs(replaceAttrs(fns, groups, attrs)...)
+	return r
+}
+
+// replaceContextAttrs returns c with the functions fns applied to its
+// attributes. The returned segments are not cached.
+func replaceContextAttrs(c ContextAttrs, fns []func([]string, slog.Attr) slog.Attr) ContextAttrs {
+	segs := []AttrSegment{}
+	c.Range(func(seg AttrSegment) {
+		segs = append(segs, AttrSegment{attrs: replaceAttrs(fns, nil, seg.attrs)})
+	})
+	return ContextAttrs{segs: segs}
+}
+
+// replaceAttrs returns attrs, in the groups named groups, with the functions
+// fns applied.
+func replaceAttrs(fns []func([]string, slog.Attr) slog.Attr, groups []string, attrs []slog.Attr) []slog.Attr {
+	out := make([]slog.Attr, 0, len(attrs))
+	for _, a := range attrs {
+		a.Value = a.Value.Resolve()
+		if a.Value.Kind() == slog.KindGroup {
+			inner := groups
+			if a.Key != "" {
+				inner = append(groups[:len(groups):len(groups)], a.Key)
+			}
+			a.Value = slog.GroupValue(replaceAttrs(fns, inner, a.Value.Group())...)
+			out = append(out, a)
+			continue
+		}
+		for _, fn := range fns {
+			if a = fn(groups, a); a.Key == "" {
+				break
+			}
+		}
+		if a.Key != "" {
+			out = append(out, a)
+		}
+	}
+	return out
+}
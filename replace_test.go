@@ -0,0 +1,64 @@
+package slogctx_test
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/jellevandenhooff/slogctx"
+	"golang.org/x/exp/slog"
+)
+
+func TestWithReplaceAttr(t *testing.T) {
+	for _, ctxAttrs := range []bool{false, true} {
+		var buf bytes.Buffer
+		var inner slog.Handler
+		if ctxAttrs {
+			inner = slogctx.NewTextHandler(&buf, slog.HandlerOptions{})
+		} else {
+			inner = slog.HandlerOptions{}.NewTextHandler(&buf)
+		}
+		// The functions do not apply to attributes added with With, before or
+		// after WithGroup.
+		logger := slog.New(slogctx.WrapWithCtxHandler(inner)).With("with", 1, "tenant", "with").WithGroup("g").With("tenant", "group")
+
+		ctx := slogctx.WithAttrs(context.Background(), "tenant", "acme", "body", "secret")
+		plain := ctx
+
+		var calls []string
+		ctx = slogctx.WithReplaceAttr(ctx, func(groups []string, a slog.Attr) slog.Attr {
+			calls = append(calls, strings.Join(append(groups, a.Key), "."))
+			switch a.Key {
+			case "body":
+				a.Value = slog.StringValue("<omitted>")
+			case "debug":
+				return slog.Attr{}
+			}
+			return a
+		})
+		ctx = slogctx.WithReplaceAttr(ctx, func(groups []string, a slog.Attr) slog.Attr {
+			if a.Key == "tenant" {
+				a.Key = "customer"
+			}
+			if a.Key == "body" {
+				a.Value = slog.StringValue(a.Value.String() + "!")
+			}
+			return a
+		})
+		ctx = slogctx.WithAttrs(ctx, "late", 2)
+
+		logger.WithContext(ctx).Info("hi", "body", "request", "debug", true, slog.Group("h", slog.String("tenant", "x")))
+		logger.WithContext(plain).Info("plain", "body", "request")
+
+		got := timeAttrRE.ReplaceAllString(buf.String(), "")
+		want := []string{
+			`level=INFO msg=hi with=1 tenant=with g.tenant=group g.body=<omitted>! g.h.customer=x customer=acme body=<omitted>! late=2`,
+			`level=INFO msg=plain with=1 tenant=with g.tenant=group g.body=request tenant=acme body=secret`,
+		}
+		checkLogOutput(t, got, strings.Join(want, "~"))
+		if got, want := strings.Join(calls, " "), "g.body g.debug g.h.tenant tenant body late"; got != want {
+			t.Errorf("ctxAttrs=%v: called with %s, want %s", ctxAttrs, got, want)
+		}
+	}
+}
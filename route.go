@@ -0,0 +1,144 @@
+package slogctx
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+
+	"golang.org/x/exp/slog"
+)
+
+// HandlerMode is how WithHandler combines a handler with the handler of the
+// logger.
+type HandlerMode int
+
+const (
+	// HandlerTee sends records to the handler in addition to the handler
+	// of the logger.
+	HandlerTee HandlerMode = iota
+	// HandlerRedirect sends records only to the handler, instead of the
+	// handler of the logger and handlers previously added to the context.
+	HandlerRedirect
+)
+
+// maxDerivedCache is the maximum number of derived handlers a handlerTarget
+// caches, so that loggers created with With for each item of a long-running
+// job cannot grow its memory without bound.
+const maxDerivedCache = 64
+
+// handlerTarget is a handler added to a context with WithHandler.
+type handlerTarget struct {
+	h slog.Handler
+
+	// derived caches h with the attributes of a ctxHandler applied, keyed
+	// by the ctxHandler. It lives as long as the contexts using h.
+	derived      sync.Map
+	derivedCount atomic.Int32
+}
+
+// WithHandler returns a context whose logs are also, with HandlerTee, or
+// only, with HandlerRedirect, handled by h. This is useful to write the logs
+// of a batch job to a per-job file. Usage:
+//
+//	ctx = slogctx.WithHandler(ctx, slog.HandlerOptions{}.NewJSONHandler(jobLog), slogctx.HandlerTee)
+//
+// Records reach h with the attributes and groups added to the logger with
+// With and WithGroup, and the attributes from the context, as they reach the
+// handler of the logger. A record is handled by each handler that is enabled
+// for its level, unless the context sets a minimum level with
+// WithMinimumLevel. h is not flushed or closed by the logger's handler, but
+// Shutdown and Fatal flush it when called with the context.
+//
+// Requires a slog.Handler wrapped with WrapWithCtxHandler.
+func WithHandler(ctx context.Context, h slog.Handler, mode HandlerMode) context.Context {
+	newInfo := cloneInfo(ctx)
+	t := &handlerTarget{h: h}
+	if mode == HandlerRedirect {
+		newInfo.handlers = []*handlerTarget{t}
+		newInfo.redirect = true
+	} else {
+		n := len(newInfo.handlers)
+		newInfo.handlers = append(newInfo.handlers[:n:n], t)
+	}
+	return context.WithValue(ctx, ctxKey{}, &newInfo)
+}
+
+// handler returns the handler of t with the attributes passed to WithAttrs on
+// h, and its parents, applied.
+func (t *handlerTarget) handler(h *ctxHandler) slog.Handler {
+	if h.handlerAttrs == nil {
+		return t.h
+	}
+	if th, ok := t.derived.Load(h); ok {
+		return th.(slog.Handler)
+	}
+	th := t.h
+	for _, attrs := range h.handlerAttrs {
+		th = th.WithAttrs(attrs)
+	}
+	if t.derivedCount.Add(1) <= maxDerivedCache {
+		t.derived.Store(h, th)
+	}
+	return th
+}
+
+// targetsEnabled reports whether the handlers in info are enabled for level.
+func (info *ctxInfo) targetsEnabled(ctx context.Context, level slog.Level, inner slog.Handler) bool {
+	for _, t := range info.handlers {
+		if t.h.Enabled(ctx, level) {
+			return true
+		}
+	}
+	return !info.redirect && inner.Enabled(ctx, level)
+}
+
+// handleTargets handles r with the handlers added to the context with
+// WithHandler and, unless one redirects, the inner handler.
+func (h *ctxHandler) handleTargets(r slog.Record, info *ctxInfo, stack Stack, warn func(key string)) error {
+	r = resolveLazy(r)
+	ctxAttrs := ContextAttrs{info: info, extra: contextExtra(info, stack)}
+	if info.replaceAttr != nil {
+		ctxAttrs = replaceContextAttrs(ctxAttrs, info.replaceAttr)
+	}
+	if h.opts.keys != nil {
+		r = h.opts.keys.record(r, warn)
+		ctxAttrs = h.opts.keys.contextAttrs(ctxAttrs, warn)
+	}
+	if ctxAttrs.segs == nil {
+		// Collect the segments once, so that functions passed to LazyAttrs
+		// run once for all handlers.
+		segs := []AttrSegment{}
+		ctxAttrs.Range(func(seg AttrSegment) {
+			segs = append(segs, seg)
+		})
+		ctxAttrs = ContextAttrs{segs: segs}
+	}
+
+	var err error
+	if !info.redirect && (info.hasLevel || h.inner.Enabled(r.Context, r.Level)) {
+		err = handleContextAttrs(h.inner, r, ctxAttrs)
+	}
+	for _, t := range info.handlers {
+		th := t.handler(h)
+		if !info.hasLevel && !th.Enabled(r.Context, r.Level) {
+			continue
+		}
+		if terr := handleContextAttrs(th, r, ctxAttrs); err == nil {
+			err = terr
+		}
+	}
+	return err
+}
+
+// handleContextAttrs passes r and attrs to h with HandleContextAttrs if h
+// implements ContextAttrsHandler, and otherwise adds attrs to a copy of r.
+func handleContextAttrs(h slog.Handler, r slog.Record, attrs ContextAttrs) error {
+	if ch, ok := h.(ContextAttrsHandler); ok {
+		return ch.HandleContextAttrs(r, attrs)
+	}
+	r = r.Clone()
+	attrs.Attrs(func(a slog.Attr) {
+		r.AddAttrs(a)
+	})
+	return h.Handle(r)
+}
@@ -0,0 +1,75 @@
+package slogctx_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/jellevandenhooff/slogctx"
+	"golang.org/x/exp/slog"
+)
+
+func TestWithHandler(t *testing.T) {
+	for _, ctxAttrs := range []bool{false, true} {
+		newHandler := func(buf *bytes.Buffer, level slog.Level) slog.Handler {
+			opts := slog.HandlerOptions{Level: level}
+			if ctxAttrs {
+				return slogctx.NewTextHandler(buf, opts)
+			}
+			return opts.NewTextHandler(buf)
+		}
+
+		var main, job, other bytes.Buffer
+		logger := slog.New(slogctx.WrapWithCtxHandler(newHandler(&main, slog.LevelInfo))).With("with", 1).WithGroup("g")
+
+		ctx := slogctx.WithAttrs(context.Background(), "request", 7)
+		calls := 0
+		ctx = slogctx.LazyAttrs(ctx, func() []any {
+			calls++
+			return []any{"lazy", calls}
+		})
+		tee := slogctx.WithHandler(ctx, newHandler(&job, slog.LevelDebug), slogctx.HandlerTee)
+		redirect := slogctx.WithHandler(tee, newHandler(&other, slog.LevelInfo), slogctx.HandlerRedirect)
+
+		logger.WithContext(ctx).Info("plain", "a", 1)
+		logger.WithContext(tee).Info("tee", "a", 2)
+		logger.WithContext(tee).Debug("debug", "a", 3)
+		logger.WithContext(redirect).Info("redirect", "a", 4)
+		if logger.WithContext(redirect).Enabled(slog.LevelDebug) {
+			t.Errorf("ctxAttrs=%v: debug enabled after redirect", ctxAttrs)
+		}
+		logger.WithContext(slogctx.WithMinimumLevel(redirect, slog.LevelDebug)).Debug("forced", "a", 5)
+
+		checkLogOutput(t, timeAttrRE.ReplaceAllString(main.String(), ""), strings.Join([]string{
+			`level=INFO msg=plain with=1 g.a=1 request=7 lazy=1`,
+			`level=INFO msg=tee with=1 g.a=2 request=7 lazy=2`,
+		}, "~"))
+		checkLogOutput(t, timeAttrRE.ReplaceAllString(job.String(), ""), strings.Join([]string{
+			`level=INFO msg=tee with=1 g.a=2 request=7 lazy=2`,
+			`level=DEBUG msg=debug with=1 g.a=3 request=7 lazy=3`,
+		}, "~"))
+		checkLogOutput(t, timeAttrRE.ReplaceAllString(other.String(), ""), strings.Join([]string{
+			`level=INFO msg=redirect with=1 g.a=4 request=7 lazy=4`,
+			`level=DEBUG msg=forced with=1 g.a=5 request=7 lazy=5`,
+		}, "~"))
+	}
+}
+
+func TestWithHandlerDerivedCache(t *testing.T) {
+	var job bytes.Buffer
+	logger := slog.New(slogctx.WrapWithCtxHandler(slog.HandlerOptions{}.NewTextHandler(io.Discard)))
+	ctx := slogctx.WithHandler(context.Background(), slog.HandlerOptions{}.NewTextHandler(&job), slogctx.HandlerRedirect)
+
+	const items = 200
+	for i := 0; i < items; i++ {
+		logger.With("item", i).WithContext(ctx).Info("processed")
+	}
+	if got := strings.Count(job.String(), "msg=processed item="); got != items {
+		t.Errorf("got %d records, want %d", got, items)
+	}
+	if got := slogctx.DerivedHandlersForTest(ctx); got > 64 {
+		t.Errorf("got %d cached handlers, want at most 64", got)
+	}
+}
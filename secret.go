@@ -0,0 +1,60 @@
+package slogctx
+
+import (
+	"fmt"
+
+	"golang.org/x/exp/slog"
+)
+
+// RedactedValue is the value logged for secrets.
+const RedactedValue = "[REDACTED]"
+
+// Secret wraps a value that must not be logged, like a password or a token.
+// It logs as RedactedValue, and formats as RedactedValue with the fmt
+// package, so that it is masked by any handler. A RedactHandler with
+// RedactHash replaces it with a keyed hash of the value instead. Usage:
+//
+//	ctx = slogctx.WithAttrs(ctx, "token", slogctx.NewSecret(token))
+type Secret[T any] struct {
+	value T
+}
+
+// NewSecret returns a Secret wrapping v.
+func NewSecret[T any](v T) Secret[T] {
+	return Secret[T]{value: v}
+}
+
+// Value returns the wrapped value.
+func (s Secret[T]) Value() T {
+	return s.value
+}
+
+// LogValue implements slog.LogValuer. It returns RedactedValue.
+func (s Secret[T]) LogValue() slog.Value {
+	return slog.StringValue(RedactedValue)
+}
+
+// String returns RedactedValue.
+func (s Secret[T]) String() string {
+	return RedactedValue
+}
+
+// Format implements fmt.Formatter. It writes RedactedValue for all verbs.
+func (s Secret[T]) Format(f fmt.State, verb rune) {
+	fmt.Fprint(f, RedactedValue)
+}
+
+// MarshalText implements encoding.TextMarshaler. It returns RedactedValue.
+func (s Secret[T]) MarshalText() ([]byte, error) {
+	return []byte(RedactedValue), nil
+}
+
+// secretValue returns the wrapped value for RedactHash.
+func (s Secret[T]) secretValue() any {
+	return s.value
+}
+
+// secret is implemented by all Secret types.
+type secret interface {
+	secretValue() any
+}
@@ -77,6 +77,33 @@ func TestWithAttrs(t *testing.T) {
 	check(`level=INFO msg=attr attr=1 buz=boo attr=str`)
 }
 
+func TestWithAttrsDeep(t *testing.T) {
+	check := setupTestSlogHandler(t, slog.HandlerOptions{})
+
+	// setup slogctx
+	slogctx.WrapDefaultLoggerWithCtxHandler()
+
+	ctx := context.Background()
+	want := `level=INFO msg=hi`
+	for i := 0; i < 40; i++ {
+		ctx = slogctx.WithAttrs(ctx, fmt.Sprintf("a%d", i), i)
+		want += fmt.Sprintf(" a%d=%d", i, i)
+
+		// exercise the flattened attributes both before and after deriving
+		// other contexts.
+		sibling := slogctx.WithMinimumLevel(slogctx.WithAttrs(ctx, "sibling", true), slog.LevelDebug)
+		slogctx.Debug(sibling, "hi")
+		check(want[:len("level=")] + "DEBUG" + want[len("level=INFO"):] + " sibling=true")
+
+		slogctx.Info(ctx, "hi")
+		check(want)
+	}
+
+	empty := slogctx.WithAttrs(ctx)
+	slogctx.Info(empty, "hi")
+	check(want)
+}
+
 func TestWithMinimumLevel(t *testing.T) {
 	check := setupTestSlogHandler(t, slog.HandlerOptions{})
 
@@ -189,10 +216,33 @@ func TestWithGroup(t *testing.T) {
 
 			logger.WithContext(ctx).Info("hi", "logattr", "logval")
 			check(`level=INFO msg=hi group1.attr1=val1 group1.attr2=val2 group1.group2.logattr=logval` + suffix)
+
+			logger.WithGroup("empty").WithContext(ctx).Info("hi")
+			check(`level=INFO msg=hi group1.attr1=val1 group1.attr2=val2` + suffix)
+
+			logger.With("attr3", "val3").WithGroup("group3").WithContext(ctx).Info("hi", "logattr", "logval")
+			check(`level=INFO msg=hi group1.attr1=val1 group1.attr2=val2 group1.group2.attr3=val3 group1.group2.group3.logattr=logval` + suffix)
 		})
 	}
 }
 
+// TestNestedCtxHandler verifies that a ctxHandler wrapping another ctxHandler
+// adds the attributes from the context once.
+func TestNestedCtxHandler(t *testing.T) {
+	check := setupTestSlogHandler(t, slog.HandlerOptions{})
+	slogctx.WrapDefaultLoggerWithCtxHandler()
+	slogctx.WrapDefaultLoggerWithCtxHandler()
+	inner := slog.Default().With("a", 1).WithGroup("inner")
+	logger := slog.New(slogctx.WrapWithCtxHandler(inner.Handler())).WithGroup("outer")
+
+	ctx := slogctx.WithAttrs(context.Background(), "attr", 1)
+	slog.Default().WithContext(ctx).Info("hi")
+	check(`level=INFO msg=hi attr=1`)
+
+	logger.WithContext(ctx).Info("hi", "b", 2)
+	check(`level=INFO msg=hi a=1 inner.outer.b=2 attr=1`)
+}
+
 // TestWrapperSourceAndContext verifies that the context is forwarded and
 // correct source line is printed with all wrappers.
 func TestWrapperSourceAndContext(t *testing.T) {
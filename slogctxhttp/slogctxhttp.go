@@ -0,0 +1,154 @@
+// Package slogctxhttp provides HTTP middleware returning the logs of a request
+// to the client, for debug tooling.
+//
+// Collect collects the records logged using the request's context with
+// slogctx.WithCollector when the request carries a DebugHeader signed with a
+// shared key, and returns them as JSON in the LogsTrailer trailer of the
+// response:
+//
+//	handler = slogctxhttp.Collect(handler, slogctxhttp.Options{Key: key})
+//
+// Clients sign requests with Sign:
+//
+//	req.Header.Set(slogctxhttp.DebugHeader, slogctxhttp.Sign(key, req, time.Now()))
+package slogctxhttp
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"github.com/jellevandenhooff/slogctx"
+)
+
+const (
+	// DebugHeader is the request header holding the signature created by
+	// Sign.
+	DebugHeader = "Slogctx-Debug"
+	// LogsTrailer is the response trailer holding the collected records,
+	// encoded as JSON by slogctx.Collector.MarshalJSON, with all characters
+	// that are not printable ASCII escaped.
+	LogsTrailer = "Slogctx-Logs"
+)
+
+// Options are options for Collect.
+type Options struct {
+	// Key is the HMAC key signing DebugHeader. It must not be empty.
+	Key []byte
+
+	// MaxAge is how far the time of a signature may be from the current
+	// time. The default is 5 minutes.
+	MaxAge time.Duration
+
+	// Collector configures the collector of the records.
+	Collector slogctx.CollectorOptions
+
+	// Clock, if non-nil, replaces the system clock for checking signatures.
+	Clock slogctx.Clock
+}
+
+// Collect returns a handler calling next, which collects the records logged
+// using the request's context if the request has a valid DebugHeader, and
+// sets them in the LogsTrailer trailer of the response after next returns.
+// Requests without a valid header are passed to next unchanged.
+//
+// HTTP/1.1 responses only carry trailers if they are chunked, so the records
+// are lost if next sets a Content-Length header. Collect panics if opts.Key is empty.
+func Collect(next http.Handler, opts Options) http.Handler {
+	if len(opts.Key) == 0 {
+		panic("slogctxhttp: Collect requires Options.Key")
+	}
+	if opts.MaxAge <= 0 {
+		opts.MaxAge = 5 * time.Minute
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sig := r.Header.Get(DebugHeader)
+		if sig == "" || !verify(opts, r, sig) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		ctx, c := slogctx.WithCollector(r.Context(), opts.Collector)
+		// Declaring the trailer before the response is written makes
+		// net/http chunk it.
+		w.Header().Add("Trailer", LogsTrailer)
+		next.ServeHTTP(w, r.WithContext(ctx))
+		data, err := json.Marshal(c)
+		if err != nil {
+			return
+		}
+		w.Header().Set(LogsTrailer, asciiJSON(data))
+	})
+}
+
+// Sign returns the value of DebugHeader for r at time t, signed with key. The
+// signature covers t and the method, host, path, and query of r, so it can
+// only be replayed for the same request within Options.MaxAge.
+func Sign(key []byte, r *http.Request, t time.Time) string {
+	ts := strconv.FormatInt(t.Unix(), 10)
+	return ts + "." + hex.EncodeToString(mac(key, r, ts))
+}
+
+// mac returns the HMAC-SHA256 of the timestamp ts and the method, host, path,
+// and query of r.
+func mac(key []byte, r *http.Request, ts string) []byte {
+	host := r.Host
+	if host == "" {
+		host = r.URL.Host
+	}
+	m := hmac.New(sha256.New, key)
+	m.Write([]byte(ts + "\n" + r.Method + "\n" + host + "\n" + r.URL.RequestURI()))
+	return m.Sum(nil)
+}
+
+// asciiJSON returns the JSON encoding data with all characters that are not
+// printable ASCII escaped, so that it is a valid HTTP header value. Such
+// characters only occur in strings, where \u escapes are valid.
+func asciiJSON(data []byte) string {
+	var b strings.Builder
+	for len(data) > 0 {
+		r, size := utf8.DecodeRune(data)
+		data = data[size:]
+		switch {
+		case r >= 0x20 && r < 0x7f:
+			b.WriteRune(r)
+		case r > 0xffff:
+			r1, r2 := utf16.EncodeRune(r)
+			fmt.Fprintf(&b, `\u%04x\u%04x`, r1, r2)
+		default:
+			fmt.Fprintf(&b, `\u%04x`, r)
+		}
+	}
+	return b.String()
+}
+
+// verify reports whether sig is a valid signature of r within opts.MaxAge.
+func verify(opts Options, r *http.Request, sig string) bool {
+	ts, hexMAC, ok := strings.Cut(sig, ".")
+	if !ok {
+		return false
+	}
+	unix, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil {
+		return false
+	}
+	got, err := hex.DecodeString(hexMAC)
+	if err != nil || !hmac.Equal(got, mac(opts.Key, r, ts)) {
+		return false
+	}
+	var now time.Time
+	if opts.Clock != nil {
+		now = opts.Clock.Now()
+	} else {
+		now = time.Now()
+	}
+	age := now.Sub(time.Unix(unix, 0))
+	return age <= opts.MaxAge && age >= -opts.MaxAge
+}
@@ -0,0 +1,105 @@
+package slogctxhttp_test
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/jellevandenhooff/slogctx"
+	"github.com/jellevandenhooff/slogctx/slogctxhttp"
+	"github.com/jellevandenhooff/slogctx/slogctxtest"
+	"golang.org/x/exp/slog"
+)
+
+func TestCollect(t *testing.T) {
+	key := []byte("secret")
+	// Header values must be printable ASCII.
+	const text = "héllo\x7f\x01 🙂"
+	now := time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC)
+	logger := slogctx.NewLogger(slog.New(slogctx.WrapWithCtxHandler(slog.HandlerOptions{}.NewTextHandler(io.Discard))))
+
+	handler := slogctxhttp.Collect(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		logger.Info(r.Context(), "handling", "path", r.URL.Path, "text", text)
+		io.WriteString(w, "ok")
+	}), slogctxhttp.Options{Key: key, Clock: slogctxtest.NewFakeClock(now, 0)})
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	for _, tt := range []struct {
+		name    string
+		path    string
+		sign    func(r *http.Request) string
+		records int
+	}{
+		{"valid", "/a", func(r *http.Request) string { return slogctxhttp.Sign(key, r, now.Add(-time.Minute)) }, 1},
+		{"missing", "/a", func(r *http.Request) string { return "" }, -1},
+		{"wrong key", "/a", func(r *http.Request) string { return slogctxhttp.Sign([]byte("other"), r, now) }, -1},
+		{"expired", "/a", func(r *http.Request) string { return slogctxhttp.Sign(key, r, now.Add(-time.Hour)) }, -1},
+		{"other path", "/b", func(r *http.Request) string {
+			signed, _ := http.NewRequest("GET", server.URL+"/a", nil)
+			return slogctxhttp.Sign(key, signed, now)
+		}, -1},
+		{"other method", "/a", func(r *http.Request) string {
+			signed, _ := http.NewRequest("POST", server.URL+"/a", nil)
+			return slogctxhttp.Sign(key, signed, now)
+		}, -1},
+		{"other query", "/a?x=1", func(r *http.Request) string {
+			signed, _ := http.NewRequest("GET", server.URL+"/a?x=2", nil)
+			return slogctxhttp.Sign(key, signed, now)
+		}, -1},
+		{"malformed", "/a", func(r *http.Request) string { return "123.xyz" }, -1},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest("GET", server.URL+tt.path, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if sig := tt.sign(req); sig != "" {
+				req.Header.Set(slogctxhttp.DebugHeader, sig)
+			}
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+			if _, err := io.ReadAll(resp.Body); err != nil {
+				t.Fatal(err)
+			}
+
+			trailer := resp.Trailer.Get(slogctxhttp.LogsTrailer)
+			if tt.records < 0 {
+				if trailer != "" {
+					t.Errorf("got trailer %s, want none", trailer)
+				}
+				return
+			}
+			for _, c := range trailer {
+				if c < 0x20 || c >= 0x7f {
+					t.Fatalf("trailer %q is not printable ASCII", trailer)
+				}
+			}
+			var logs struct {
+				Records []map[string]any
+				Dropped int
+			}
+			if err := json.Unmarshal([]byte(trailer), &logs); err != nil {
+				t.Fatalf("invalid trailer %q: %v", trailer, err)
+			}
+			if len(logs.Records) != tt.records {
+				t.Fatalf("got %d records, want %d", len(logs.Records), tt.records)
+			}
+			if got := logs.Records[0]["msg"]; got != "handling" {
+				t.Errorf("got message %v, want handling", got)
+			}
+			if got := logs.Records[0]["path"]; got != tt.path {
+				t.Errorf("got path %v, want %s", got, tt.path)
+			}
+			if got := logs.Records[0]["text"]; got != text {
+				t.Errorf("got text %q, want %q", got, text)
+			}
+		})
+	}
+}
@@ -0,0 +1,55 @@
+package slogctxtest
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// FakeClock is a slogctx.Clock for tests that advances deterministically.
+// Each call to Now advances the clock by a fixed step, so that durations
+// measured with the clock are stable.
+type FakeClock struct {
+	mu   sync.Mutex
+	now  time.Time
+	step time.Duration
+}
+
+// NewFakeClock returns a FakeClock starting at start, that advances by step
+// after each call to Now.
+func NewFakeClock(start time.Time, step time.Duration) *FakeClock {
+	return &FakeClock{now: start, step: step}
+}
+
+// Now returns the current time of the clock, and then advances the clock by
+// its step.
+func (c *FakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := c.now
+	c.now = c.now.Add(c.step)
+	return now
+}
+
+// Advance advances the clock by d.
+func (c *FakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+// FakeIDGenerator is a slogctx.IDGenerator for tests that returns sequential
+// IDs formatted like random slogctx IDs, starting at "0000000000000001". The
+// zero FakeIDGenerator is ready to use.
+type FakeIDGenerator struct {
+	mu sync.Mutex
+	n  uint64
+}
+
+// NewID returns the next ID.
+func (g *FakeIDGenerator) NewID() string {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.n++
+	return fmt.Sprintf("%016x", g.n)
+}
@@ -0,0 +1,176 @@
+package slogctxtest
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/jellevandenhooff/slogctx"
+	"golang.org/x/exp/slog"
+)
+
+// update is namespaced, as test packages commonly define their own -update
+// flag, and registering a flag twice panics.
+var update = flag.Bool("slogctxtest.update", false, "update golden log files written by slogctxtest.Golden in testdata")
+
+// DefaultIDKeys are the keys of attributes holding random IDs, whose values
+// Golden replaces with placeholders.
+var DefaultIDKeys = []string{slogctx.SpanIDKey, slogctx.ParentSpanIDKey, "requestID"}
+
+// GoldenOptions are options for Golden. A zero GoldenOptions consists entirely
+// of default values.
+type GoldenOptions struct {
+	// JSON writes records as JSON instead of text.
+	JSON bool
+
+	// IDKeys are the keys of attributes holding random IDs, in addition to
+	// DefaultIDKeys.
+	IDKeys []string
+}
+
+// Golden returns a context and a logger that capture records like New, and
+// compares the captured records with the file testdata/<test name>.golden
+// when the test finishes. If the test is run with the -slogctxtest.update
+// flag, as in "go test -slogctxtest.update", Golden writes the file instead.
+//
+// To keep the output stable, the captured records are normalized: times are
+// omitted, sources are reduced to their file name, durations are replaced by
+// "{duration}", and values of ID attributes are replaced by placeholders like
+// "{id1}", numbered in order of appearance, so that equal IDs have equal
+// placeholders. Usage:
+//
+//	func TestServer(t *testing.T) {
+//		ctx, _ := slogctxtest.Golden(t, slogctxtest.GoldenOptions{})
+//		handleRequest(ctx)
+//	}
+func Golden(t *testing.T, opts GoldenOptions) (context.Context, *slogctx.Logger) {
+	t.Helper()
+
+	g := &golden{ids: make(map[string]string), idKeys: make(map[string]bool)}
+	for _, key := range DefaultIDKeys {
+		g.idKeys[key] = true
+	}
+	for _, key := range opts.IDKeys {
+		g.idKeys[key] = true
+	}
+	handlerOpts := slog.HandlerOptions{
+		AddSource:   true,
+		Level:       slog.LevelDebug,
+		ReplaceAttr: g.replaceAttr,
+	}
+	var inner slog.Handler
+	if opts.JSON {
+		inner = handlerOpts.NewJSONHandler(&g.buf)
+	} else {
+		inner = handlerOpts.NewTextHandler(&g.buf)
+	}
+	h := slogctx.WrapWithCtxHandler(&goldenHandler{g: g, inner: inner})
+
+	path := filepath.Join("testdata", filepath.FromSlash(t.Name())+".golden")
+	t.Cleanup(func() {
+		g.mu.Lock()
+		defer g.mu.Unlock()
+		g.done = true
+		g.check(t, path)
+	})
+
+	installRouter(t)
+	ctx := context.WithValue(context.Background(), routeKey{}, h)
+	return ctx, slogctx.NewLogger(slog.New(h))
+}
+
+// golden holds the records captured by Golden.
+type golden struct {
+	idKeys map[string]bool
+
+	mu   sync.Mutex
+	buf  bytes.Buffer
+	ids  map[string]string // ID values to placeholders
+	done bool
+}
+
+// replaceAttr normalizes the attributes of captured records.
+func (g *golden) replaceAttr(groups []string, a slog.Attr) slog.Attr {
+	if len(groups) == 0 {
+		switch a.Key {
+		case slog.TimeKey:
+			return slog.Attr{}
+		case slog.SourceKey:
+			file, _, _ := strings.Cut(filepath.Base(a.Value.String()), ":")
+			return slog.String(a.Key, file)
+		}
+	}
+	if a.Value.Kind() == slog.KindDuration {
+		return slog.String(a.Key, "{duration}")
+	}
+	if g.idKeys[a.Key] {
+		id := a.Value.String()
+		placeholder, ok := g.ids[id]
+		if !ok {
+			placeholder = fmt.Sprintf("{id%d}", len(g.ids)+1)
+			g.ids[id] = placeholder
+		}
+		return slog.String(a.Key, placeholder)
+	}
+	return a
+}
+
+// check compares the captured records with the golden file at path, or
+// writes them to it if the -slogctxtest.update flag is set. g.mu must be held.
+func (g *golden) check(t *testing.T, path string) {
+	t.Helper()
+	got := g.buf.Bytes()
+	if *update {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, got, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("reading golden file (run with -slogctxtest.update to create it): %v", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("log output differs from %s (run with -slogctxtest.update to update it):\ngot:\n%s\nwant:\n%s", path, got, want)
+	}
+}
+
+// goldenHandler passes records to a handler writing to the buffer of a
+// golden, holding its lock.
+type goldenHandler struct {
+	g     *golden
+	inner slog.Handler
+}
+
+func (h *goldenHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.inner.Enabled(ctx, level)
+}
+
+func (h *goldenHandler) Handle(r slog.Record) error {
+	h.g.mu.Lock()
+	defer h.g.mu.Unlock()
+	if h.g.done {
+		return nil
+	}
+	return h.inner.Handle(r)
+}
+
+func (h *goldenHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	// The inner handler calls replaceAttr on attrs.
+	h.g.mu.Lock()
+	defer h.g.mu.Unlock()
+	return &goldenHandler{g: h.g, inner: h.inner.WithAttrs(attrs)}
+}
+
+func (h *goldenHandler) WithGroup(name string) slog.Handler {
+	return &goldenHandler{g: h.g, inner: h.inner.WithGroup(name)}
+}
@@ -0,0 +1,45 @@
+package slogctxtest_test
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"testing"
+
+	"github.com/jellevandenhooff/slogctx"
+	"github.com/jellevandenhooff/slogctx/slogctxtest"
+	"golang.org/x/exp/slog"
+)
+
+// update is the flag test packages commonly define for their own golden
+// files. Defining it must not conflict with the flag of Golden.
+var _ = flag.Bool("update", false, "update golden files in testdata")
+
+// serve logs like a request handler.
+func serve(ctx context.Context, requestID string) {
+	ctx = slogctx.WithAttrs(ctx, "requestID", requestID)
+	ctx, end := slogctx.Start(ctx, "request", "path", "/messages")
+	slogctx.Info(ctx, "querying")
+	_, endQuery := slogctx.Start(ctx, "query")
+	endQuery(errors.New("timeout"))
+	end(nil)
+}
+
+func TestGolden(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		opts slogctxtest.GoldenOptions
+	}{
+		{"text", slogctxtest.GoldenOptions{}},
+		{"json", slogctxtest.GoldenOptions{JSON: true, IDKeys: []string{"session"}}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx, logger := slogctxtest.Golden(t, tc.opts)
+			ctx = slogctx.WithAttrs(ctx, "session", "f00d")
+			logger.Inner.With("component", "server").WithGroup("g").WithContext(ctx).Debug("starting", "port", 8080)
+			serve(ctx, "8c2d4e0f")
+			serve(ctx, "1b7a9c3e")
+			slog.Default().WithContext(ctx).Info("via default logger")
+		})
+	}
+}
@@ -0,0 +1,319 @@
+package slogctxtest
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jellevandenhooff/slogctx"
+	"golang.org/x/exp/slog"
+)
+
+// Record is a record stored by a Recorder.
+type Record struct {
+	Time    time.Time
+	Level   slog.Level
+	Message string
+
+	// Attrs holds all attributes of the record, including the attributes from
+	// the context, keyed by their key qualified with the names of their
+	// groups, as in "group.key". Values are resolved, and never groups.
+	Attrs map[string]slog.Value
+
+	// ContextAttrs holds the attributes from the context, like Attrs.
+	ContextAttrs map[string]slog.Value
+}
+
+// String formats the record for test failures.
+func (r Record) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "level=%s msg=%q", r.Level, r.Message)
+	keys := make([]string, 0, len(r.Attrs))
+	for k := range r.Attrs {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fmt.Fprintf(&b, " %s=%v", k, r.Attrs[k])
+	}
+	return b.String()
+}
+
+// Matches reports whether r has the given level and message, and the
+// attributes given as key-value pairs or slog.Attrs in args.
+func (r Record) Matches(level slog.Level, msg string, args ...any) bool {
+	return r.Level == level && r.Message == msg && hasAttrs(r.Attrs, args)
+}
+
+// Recorder is a handler that stores records for assertions in tests. It
+// stores records at all levels.
+//
+// Use the Recorder wrapped with slogctx.WrapWithCtxHandler, for example
+// through Logger. Attributes from the context are then stored both in
+// Record.Attrs and Record.ContextAttrs. Usage:
+//
+//	rec := slogctxtest.NewRecorder()
+//	ctx := slogctx.WithAttrs(ctx, "requestID", id)
+//	rec.Logger().Info(ctx, "handled", "status", 200)
+//	rec.WithContextAttrs("requestID", id).RequireLogged(t, slog.LevelInfo, "handled", "status", 200)
+type Recorder struct {
+	store  *recordStore
+	filter []slog.Attr
+}
+
+// recordStore holds the records of a Recorder and the handlers derived from
+// it.
+type recordStore struct {
+	mu      sync.Mutex
+	records []Record
+}
+
+// NewRecorder returns an empty Recorder.
+func NewRecorder() *Recorder {
+	return &Recorder{store: &recordStore{}}
+}
+
+// Handler returns the handler storing records in the Recorder.
+func (r *Recorder) Handler() slog.Handler {
+	return &recordHandler{store: r.store}
+}
+
+// Logger returns a logger storing records in the Recorder, with the handler
+// wrapped with slogctx.WrapWithCtxHandler.
+func (r *Recorder) Logger() *slogctx.Logger {
+	return slogctx.NewLogger(slog.New(slogctx.WrapWithCtxHandler(r.Handler())))
+}
+
+// WithContextAttrs returns a Recorder sharing the records of r, that only
+// considers records with the context attributes given as key-value pairs or
+// slog.Attrs in args.
+func (r *Recorder) WithContextAttrs(args ...any) *Recorder {
+	filter := make([]slog.Attr, 0, len(r.filter)+len(args))
+	filter = append(filter, r.filter...)
+	filter = append(filter, argsToAttrs(args)...)
+	return &Recorder{store: r.store, filter: filter}
+}
+
+// Records returns the stored records, oldest first.
+func (r *Recorder) Records() []Record {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	var records []Record
+	for _, rec := range r.store.records {
+		if hasAttrs(rec.ContextAttrs, r.filter) {
+			records = append(records, rec)
+		}
+	}
+	return records
+}
+
+// Find returns the stored records with the given level, message, and
+// attributes, as in Record.Matches.
+func (r *Recorder) Find(level slog.Level, msg string, args ...any) []Record {
+	var found []Record
+	for _, rec := range r.Records() {
+		if rec.Matches(level, msg, args...) {
+			found = append(found, rec)
+		}
+	}
+	return found
+}
+
+// RequireLogged fails the test unless a record with the given level, message,
+// and attributes was stored, and returns the first such record.
+func (r *Recorder) RequireLogged(t testing.TB, level slog.Level, msg string, args ...any) Record {
+	t.Helper()
+	found := r.Find(level, msg, args...)
+	if len(found) == 0 {
+		t.Fatalf("expected record level=%s msg=%q%s, got:\n%s", level, msg, formatArgs(args), r.dump())
+	}
+	return found[0]
+}
+
+// NotLogged fails the test if a record with the given level, message, and
+// attributes was stored.
+func (r *Recorder) NotLogged(t testing.TB, level slog.Level, msg string, args ...any) {
+	t.Helper()
+	if found := r.Find(level, msg, args...); len(found) > 0 {
+		t.Errorf("expected no record level=%s msg=%q%s, got:\n\t%s", level, msg, formatArgs(args), found[0])
+	}
+}
+
+// Reset removes all stored records, including those not matching the context
+// attributes of r.
+func (r *Recorder) Reset() {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	r.store.records = nil
+}
+
+// dump formats the records of r for test failures.
+func (r *Recorder) dump() string {
+	records := r.Records()
+	if len(records) == 0 {
+		return "\t(no records)"
+	}
+	var b strings.Builder
+	for i, rec := range records {
+		if i > 0 {
+			b.WriteByte('\n')
+		}
+		b.WriteString("\t")
+		b.WriteString(rec.String())
+	}
+	return b.String()
+}
+
+// recordHandler is the handler of a Recorder.
+type recordHandler struct {
+	store  *recordStore
+	attrs  map[string]slog.Value // from WithAttrs
+	prefix string                // from WithGroup, ending in "."
+}
+
+func (h *recordHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return true
+}
+
+func (h *recordHandler) Handle(r slog.Record) error {
+	return h.HandleContextAttrs(r, slogctx.ContextAttrs{})
+}
+
+// HandleContextAttrs implements slogctx.ContextAttrsHandler.
+func (h *recordHandler) HandleContextAttrs(r slog.Record, ctxAttrs slogctx.ContextAttrs) error {
+	rec := Record{
+		Time:         r.Time,
+		Level:        r.Level,
+		Message:      r.Message,
+		Attrs:        make(map[string]slog.Value, len(h.attrs)+r.NumAttrs()),
+		ContextAttrs: make(map[string]slog.Value),
+	}
+	for k, v := range h.attrs {
+		rec.Attrs[k] = v
+	}
+	r.Attrs(func(a slog.Attr) {
+		flatten(rec.Attrs, h.prefix, a)
+	})
+	ctxAttrs.Attrs(func(a slog.Attr) {
+		flatten(rec.ContextAttrs, "", a)
+	})
+	for k, v := range rec.ContextAttrs {
+		rec.Attrs[k] = v
+	}
+
+	h.store.mu.Lock()
+	defer h.store.mu.Unlock()
+	h.store.records = append(h.store.records, rec)
+	return nil
+}
+
+func (h *recordHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	newAttrs := make(map[string]slog.Value, len(h.attrs)+len(attrs))
+	for k, v := range h.attrs {
+		newAttrs[k] = v
+	}
+	for _, a := range attrs {
+		flatten(newAttrs, h.prefix, a)
+	}
+	return &recordHandler{store: h.store, attrs: newAttrs, prefix: h.prefix}
+}
+
+func (h *recordHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	return &recordHandler{store: h.store, attrs: h.attrs, prefix: h.prefix + name + "."}
+}
+
+// flatten stores the resolved value of a in m under its key qualified with
+// prefix, and the attributes of groups under their qualified keys. Like the
+// slog handlers, it ignores empty attributes and inlines groups with empty
+// keys.
+func flatten(m map[string]slog.Value, prefix string, a slog.Attr) {
+	v := a.Value.Resolve()
+	if v.Kind() != slog.KindGroup {
+		if a.Key != "" {
+			m[prefix+a.Key] = v
+		}
+		return
+	}
+	if a.Key != "" {
+		prefix += a.Key + "."
+	}
+	for _, aa := range v.Group() {
+		flatten(m, prefix, aa)
+	}
+}
+
+// hasAttrs reports whether m holds all attributes in args, which are
+// key-value pairs or slog.Attrs, or a []slog.Attr.
+func hasAttrs(m map[string]slog.Value, args any) bool {
+	var attrs []slog.Attr
+	switch args := args.(type) {
+	case []slog.Attr:
+		attrs = args
+	case []any:
+		attrs = argsToAttrs(args)
+	}
+	want := make(map[string]slog.Value, len(attrs))
+	for _, a := range attrs {
+		flatten(want, "", a)
+	}
+	for k, v := range want {
+		got, ok := m[k]
+		if !ok || !valuesEqual(got, v) {
+			return false
+		}
+	}
+	return true
+}
+
+// valuesEqual reports whether v and w are equal, comparing values of kind
+// slog.KindAny with reflect.DeepEqual.
+func valuesEqual(v, w slog.Value) bool {
+	if v.Kind() == slog.KindAny && w.Kind() == slog.KindAny {
+		return reflect.DeepEqual(v.Any(), w.Any())
+	}
+	return v.Equal(w)
+}
+
+// formatArgs formats the attributes in args for test failures, each preceded
+// by a space.
+func formatArgs(args []any) string {
+	var b strings.Builder
+	for _, a := range argsToAttrs(args) {
+		b.WriteByte(' ')
+		b.WriteString(a.String())
+	}
+	return b.String()
+}
+
+// argsToAttrs converts key-value pairs and slog.Attrs to a slice of
+// slog.Attrs, like slog.Logger.Log does.
+func argsToAttrs(args []any) []slog.Attr {
+	var attrs []slog.Attr
+	for len(args) > 0 {
+		switch x := args[0].(type) {
+		case string:
+			if len(args) == 1 {
+				attrs = append(attrs, slog.String("!BADKEY", x))
+				args = nil
+				continue
+			}
+			attrs = append(attrs, slog.Any(x, args[1]))
+			args = args[2:]
+		case slog.Attr:
+			attrs = append(attrs, x)
+			args = args[1:]
+		default:
+			attrs = append(attrs, slog.Any("!BADKEY", x))
+			args = args[1:]
+		}
+	}
+	return attrs
+}
@@ -0,0 +1,75 @@
+package slogctxtest_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jellevandenhooff/slogctx"
+	"github.com/jellevandenhooff/slogctx/slogctxtest"
+	"golang.org/x/exp/slog"
+)
+
+func TestRecorder(t *testing.T) {
+	rec := slogctxtest.NewRecorder()
+	logger := rec.Logger()
+
+	ctx := context.Background()
+	ctx = slogctx.WithAttrs(ctx, "user", "alice", slog.Group("req", slog.Int("id", 1)))
+	logger.Info(ctx, "hello", "count", 2, slog.Group("g", slog.Any("a", []int{1})))
+	logger.Inner.With("with", true).WithGroup("grp").WithContext(ctx).Warn("grouped", "x", time.Second)
+	errBoom := errors.New("boom")
+	logger.Error(context.Background(), "failed", errBoom)
+
+	r := rec.RequireLogged(t, slog.LevelInfo, "hello", "count", 2, "user", "alice", "req.id", 1, "g.a", []int{1})
+	if _, ok := r.ContextAttrs["count"]; ok {
+		t.Errorf("expected count not to be a context attribute")
+	}
+	if got := r.ContextAttrs["req.id"]; got.Int64() != 1 {
+		t.Errorf("expected context attribute req.id=1, got %v", got)
+	}
+	rec.RequireLogged(t, slog.LevelWarn, "grouped", "with", true, "grp.x", time.Second, "user", "alice")
+	rec.RequireLogged(t, slog.LevelError, "failed", slog.ErrorKey, errBoom)
+
+	rec.NotLogged(t, slog.LevelDebug, "hello")
+	rec.NotLogged(t, slog.LevelInfo, "hello", "count", 3)
+	rec.NotLogged(t, slog.LevelInfo, "hello", "missing", 1)
+
+	if got := len(rec.Records()); got != 3 {
+		t.Errorf("expected 3 records, got %d", got)
+	}
+	rec.Reset()
+	if got := len(rec.Records()); got != 0 {
+		t.Errorf("expected no records after Reset, got %d", got)
+	}
+}
+
+func TestRecorderWithContextAttrs(t *testing.T) {
+	rec := slogctxtest.NewRecorder()
+	logger := rec.Logger()
+
+	ctx := context.Background()
+	ctx1 := slogctx.WithAttrs(ctx, "requestID", "1")
+	ctx2 := slogctx.WithAttrs(ctx, "requestID", "2")
+	logger.Info(ctx1, "handled")
+	logger.Info(ctx2, "handled", "requestID", "1")
+	logger.Info(ctx2, "other")
+
+	req1 := rec.WithContextAttrs("requestID", "1")
+	if got := len(req1.Records()); got != 1 {
+		t.Errorf("expected 1 record for request 1, got %d", got)
+	}
+	req1.RequireLogged(t, slog.LevelInfo, "handled")
+	req1.NotLogged(t, slog.LevelInfo, "other")
+	rec.WithContextAttrs("requestID", "2").RequireLogged(t, slog.LevelInfo, "other")
+}
+
+func TestRecorderFailure(t *testing.T) {
+	rec := slogctxtest.NewRecorder()
+	rec.Logger().Info(context.Background(), "hello", "a", 1)
+
+	ft := &fakeT{}
+	rec.NotLogged(ft, slog.LevelInfo, "hello")
+	ft.check(t, `expected no record level=INFO msg="hello", got:\n\tlevel=INFO msg="hello" a=1`)
+}
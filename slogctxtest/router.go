@@ -0,0 +1,173 @@
+package slogctxtest
+
+import (
+	"context"
+	"io"
+	"log"
+	"sync"
+	"testing"
+
+	"github.com/jellevandenhooff/slogctx"
+	"golang.org/x/exp/slog"
+)
+
+// routeKey is the context key for the handler of the test owning a context.
+type routeKey struct{}
+
+// routeHandler returns the test handler stored in ctx by New, or nil.
+func routeHandler(ctx context.Context) slog.Handler {
+	if ctx == nil {
+		return nil
+	}
+	h, _ := ctx.Value(routeKey{}).(slog.Handler)
+	return h
+}
+
+var (
+	installMu sync.Mutex
+	// users is the number of tests using the router.
+	users int
+	// installed is the default logger installed by installRouter, and
+	// previous the default logger and log package output it replaced.
+	installed, previous *slog.Logger
+	previousOutput      io.Writer
+	previousFlags       int
+)
+
+// installRouter makes the default logger use a router, unless it already
+// does, until t and all other tests using the router have finished.
+func installRouter(t testing.TB) {
+	installMu.Lock()
+	defer installMu.Unlock()
+	users++
+	t.Cleanup(uninstallRouter)
+
+	h := slog.Default().Handler()
+	if _, ok := h.(*router); ok {
+		return
+	}
+	// slog.SetDefault also redirects the log package to the new logger, so
+	// save its output to restore it.
+	previous, previousOutput, previousFlags = slog.Default(), log.Writer(), log.Flags()
+	installed = slog.New(&router{fallback: h})
+	slog.SetDefault(installed)
+}
+
+// uninstallRouter restores the default logger replaced by installRouter when
+// the last test using the router has finished, unless the default logger was
+// replaced since.
+func uninstallRouter() {
+	installMu.Lock()
+	defer installMu.Unlock()
+	users--
+	if users > 0 || installed == nil {
+		return
+	}
+	if slog.Default() == installed {
+		slog.SetDefault(previous)
+		log.SetOutput(previousOutput)
+		log.SetFlags(previousFlags)
+	}
+	installed, previous, previousOutput = nil, nil, nil
+}
+
+// handlerOp is a WithAttrs or WithGroup call on a router, replayed on the
+// test handler when routing a record.
+type handlerOp struct {
+	attrs   []slog.Attr
+	group   string
+	isGroup bool
+}
+
+func (op handlerOp) apply(h slog.Handler) slog.Handler {
+	if op.isGroup {
+		return h.WithGroup(op.group)
+	}
+	return h.WithAttrs(op.attrs)
+}
+
+// router passes records logged with a context created by New to the handler
+// of the owning test, and all other records to fallback.
+//
+// If the default logger is wrapped with a ctxHandler after the router is
+// installed, the ctxHandler passes the attributes from the context to
+// HandleContextAttrs, and the router passes them on to the ctxHandler of the
+// test, so that they are added once.
+type router struct {
+	fallback slog.Handler
+	ops      []handlerOp
+}
+
+func (h *router) Enabled(ctx context.Context, level slog.Level) bool {
+	if th := routeHandler(ctx); th != nil {
+		return th.Enabled(ctx, level)
+	}
+	return h.fallback.Enabled(ctx, level)
+}
+
+func (h *router) Handle(r slog.Record) error {
+	return h.target(r.Context).Handle(r)
+}
+
+func (h *router) HandleContextAttrs(r slog.Record, attrs slogctx.ContextAttrs) error {
+	return handleContextAttrs(h.target(r.Context), r, attrs)
+}
+
+// target returns the handler for records logged with ctx: the test handler
+// with the WithAttrs and WithGroup calls on h applied, or fallback.
+func (h *router) target(ctx context.Context) slog.Handler {
+	th := routeHandler(ctx)
+	if th == nil {
+		return h.fallback
+	}
+	for _, op := range h.ops {
+		th = op.apply(th)
+	}
+	return th
+}
+
+// handleContextAttrs passes r and attrs to h with HandleContextAttrs if h
+// implements slogctx.ContextAttrsHandler, and otherwise adds attrs to a copy
+// of r.
+func handleContextAttrs(h slog.Handler, r slog.Record, attrs slogctx.ContextAttrs) error {
+	if ch, ok := h.(slogctx.ContextAttrsHandler); ok {
+		return ch.HandleContextAttrs(r, attrs)
+	}
+	r = r.Clone()
+	attrs.Attrs(func(a slog.Attr) {
+		r.AddAttrs(a)
+	})
+	return h.Handle(r)
+}
+
+func (h *router) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return h.with(handlerOp{attrs: attrs})
+}
+
+func (h *router) WithGroup(name string) slog.Handler {
+	return h.with(handlerOp{group: name, isGroup: true})
+}
+
+// Flush flushes the fallback handler if it implements slogctx.Flusher.
+func (h *router) Flush() error {
+	if f, ok := h.fallback.(slogctx.Flusher); ok {
+		return f.Flush()
+	}
+	return nil
+}
+
+// Close closes the fallback handler if it implements slogctx.Closer, and
+// otherwise flushes it.
+func (h *router) Close() error {
+	if c, ok := h.fallback.(slogctx.Closer); ok {
+		return c.Close()
+	}
+	return h.Flush()
+}
+
+func (h *router) with(op handlerOp) slog.Handler {
+	ops := make([]handlerOp, len(h.ops)+1)
+	copy(ops, h.ops)
+	ops[len(ops)-1] = op
+	return &router{fallback: op.apply(h.fallback), ops: ops}
+}
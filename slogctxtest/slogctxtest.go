@@ -0,0 +1,145 @@
+// Package slogctxtest provides helpers for using slogctx in tests.
+//
+// New returns a logger that writes to a test's log, and a context that routes
+// records logged with it, including through slog.Default(), to the same test.
+// Parallel tests thus each see only their own logs:
+//
+//	func TestFoo(t *testing.T) {
+//		t.Parallel()
+//		ctx, logger := slogctxtest.New(t)
+//		logger.Info(ctx, "starting")
+//		foo(ctx) // logs from foo using slogctx.Info(ctx, ...) appear in TestFoo
+//	}
+//
+// A Recorder stores structured records to make assertions about what was
+// logged, and Golden compares logs with golden files in testdata.
+package slogctxtest
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"testing"
+
+	"github.com/jellevandenhooff/slogctx"
+	"golang.org/x/exp/slog"
+)
+
+// New returns a context and a logger that write all records at
+// slog.LevelDebug and above to the log of t. Each record is prefixed with the
+// file and line of the call that logged it. Before Go 1.25, which added
+// testing.TB.Output, records are written with t.Log, which adds its own
+// location first, as in "slogctxtest.go:123: foo_test.go:45: msg=...".
+//
+// Records logged with the returned context, or a context derived from it, on
+// the default logger are also written to t instead of the default logger's
+// handler. To do so New installs a routing handler on slog.Default, unless it
+// is already installed, which affects the whole process. Call New after
+// replacing the default logger; New reinstalls the routing handler if the
+// default logger no longer uses it. The default logger can be wrapped with
+// slogctx.WrapDefaultLoggerWithCtxHandler before or after New; records get
+// the attributes from the context once either way. When all tests using New and
+// Golden have finished, the previous default logger is restored, unless the
+// default logger was replaced in the meantime.
+//
+// Records logged after the test has finished are discarded.
+func New(t testing.TB) (context.Context, *slogctx.Logger) {
+	t.Helper()
+
+	shared := &testShared{t: t}
+	t.Cleanup(func() {
+		shared.mu.Lock()
+		defer shared.mu.Unlock()
+		shared.done = true
+	})
+	h := slogctx.WrapWithCtxHandler(&testHandler{
+		shared: shared,
+		inner: slog.HandlerOptions{
+			Level:       slog.LevelDebug,
+			ReplaceAttr: dropTime,
+		}.NewTextHandler(&shared.buf),
+	})
+
+	installRouter(t)
+	ctx := context.WithValue(context.Background(), routeKey{}, h)
+	return ctx, slogctx.NewLogger(slog.New(h))
+}
+
+// dropTime removes the time from records written to the test log.
+func dropTime(groups []string, a slog.Attr) slog.Attr {
+	if len(groups) == 0 && a.Key == slog.TimeKey {
+		return slog.Attr{}
+	}
+	return a
+}
+
+// testShared is the state shared by a testHandler and the handlers derived
+// from it.
+type testShared struct {
+	t testing.TB
+
+	mu   sync.Mutex
+	buf  bytes.Buffer
+	done bool
+}
+
+// outputer is implemented by testing.TB since Go 1.25. Output writes to the
+// test log without the location of the call, so that the location of the log
+// call added by testHandler is the only one shown.
+type outputer interface {
+	Output() io.Writer
+}
+
+// testHandler formats records with a text handler and writes them to the
+// test log.
+type testHandler struct {
+	shared *testShared
+	inner  slog.Handler
+}
+
+func (h *testHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.inner.Enabled(ctx, level)
+}
+
+func (h *testHandler) Handle(r slog.Record) error {
+	s := h.shared
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.done {
+		return nil
+	}
+
+	s.buf.Reset()
+	if err := h.inner.Handle(r); err != nil {
+		return err
+	}
+	line := caller(r.PC) + string(bytes.TrimSuffix(s.buf.Bytes(), []byte("\n")))
+	if o, ok := s.t.(outputer); ok {
+		fmt.Fprintln(o.Output(), line)
+	} else {
+		s.t.Log(line)
+	}
+	return nil
+}
+
+func (h *testHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &testHandler{shared: h.shared, inner: h.inner.WithAttrs(attrs)}
+}
+
+func (h *testHandler) WithGroup(name string) slog.Handler {
+	return &testHandler{shared: h.shared, inner: h.inner.WithGroup(name)}
+}
+
+// caller returns "file:line: " for the program counter pc, or "" if pc is
+// zero.
+func caller(pc uintptr) string {
+	if pc == 0 {
+		return ""
+	}
+	frame, _ := runtime.CallersFrames([]uintptr{pc}).Next()
+	return fmt.Sprintf("%s:%d: ", filepath.Base(frame.File), frame.Line)
+}
@@ -0,0 +1,187 @@
+package slogctxtest_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/jellevandenhooff/slogctx"
+	"github.com/jellevandenhooff/slogctx/slogctxtest"
+	"golang.org/x/exp/slog"
+)
+
+// fakeT records calls to Log and Errorf, and runs cleanups when finish is called.
+type fakeT struct {
+	testing.TB
+
+	mu       sync.Mutex
+	logs     []string
+	cleanups []func()
+}
+
+func (t *fakeT) Helper() {}
+
+func (t *fakeT) Log(args ...any) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.logs = append(t.logs, fmt.Sprint(args...))
+}
+
+func (t *fakeT) Errorf(format string, args ...any) {
+	t.Log(fmt.Sprintf(format, args...))
+}
+
+func (t *fakeT) Cleanup(f func()) {
+	t.cleanups = append(t.cleanups, f)
+}
+
+func (t *fakeT) finish() {
+	for i := len(t.cleanups) - 1; i >= 0; i-- {
+		t.cleanups[i]()
+	}
+}
+
+func (t *fakeT) check(tt *testing.T, want ...string) {
+	tt.Helper()
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if len(t.logs) != len(want) {
+		tt.Fatalf("expected %d logs, got %d:\n%s", len(want), len(t.logs), strings.Join(t.logs, "\n"))
+	}
+	for i := range want {
+		if !regexp.MustCompile("^" + want[i] + "$").MatchString(t.logs[i]) {
+			tt.Errorf("\ngot  %s\nwant %s", t.logs[i], want[i])
+		}
+	}
+	t.logs = nil
+}
+
+// useDefault makes the default logger write to a buffer for the duration of
+// the test.
+func useDefault(t *testing.T) *bytes.Buffer {
+	var buf bytes.Buffer
+	original := slog.Default()
+	slog.SetDefault(slog.New(slogctx.WrapWithCtxHandler(slog.HandlerOptions{}.NewTextHandler(&buf))))
+	t.Cleanup(func() {
+		slog.SetDefault(original)
+	})
+	return &buf
+}
+
+func TestNew(t *testing.T) {
+	useDefault(t)
+	ft := &fakeT{}
+	ctx, logger := slogctxtest.New(ft)
+
+	logger.Info(ctx, "hello", "a", 1)
+	logger.Debug(context.Background(), "debug")
+	ctx = slogctx.WithAttrs(ctx, "b", 2)
+	logger.Warn(ctx, "attrs")
+	ft.check(t,
+		`slogctxtest_test.go:81: level=INFO msg=hello a=1`,
+		`slogctxtest_test.go:82: level=DEBUG msg=debug`,
+		`slogctxtest_test.go:84: level=WARN msg=attrs b=2`,
+	)
+
+	ft.finish()
+	logger.Info(ctx, "after test")
+	ft.check(t)
+}
+
+func TestRoute(t *testing.T) {
+	buf := useDefault(t)
+	ft1, ft2 := &fakeT{}, &fakeT{}
+	ctx1, _ := slogctxtest.New(ft1)
+	ctx2, _ := slogctxtest.New(ft2)
+
+	ctx1 = slogctx.WithAttrs(ctx1, "test", 1)
+	slogctx.Info(ctx1, "one")
+	slog.Default().With("a", "b").WithGroup("g").WithContext(ctx2).Info("two", "c", "d")
+	slogctx.Info(context.Background(), "neither")
+
+	ft1.check(t, `slogctxtest_test.go:103: level=INFO msg=one test=1`)
+	ft2.check(t, `slogctxtest_test.go:104: level=INFO msg=two a=b g.c=d`)
+	if got := buf.String(); !strings.Contains(got, "msg=neither") || strings.Contains(got, "msg=one") || strings.Contains(got, "msg=two") {
+		t.Errorf("expected only unrouted records in default logger output, got:\n%s", got)
+	}
+	ft1.finish()
+	ft2.finish()
+}
+
+func TestRouteReinstall(t *testing.T) {
+	useDefault(t)
+	ft := &fakeT{}
+	slogctxtest.New(ft)
+
+	// Replacing the default logger removes the router, and New installs it
+	// again.
+	buf := useDefault(t)
+	ctx, _ := slogctxtest.New(ft)
+	slogctx.Info(ctx, "routed")
+	ft.check(t, `slogctxtest_test.go:125: level=INFO msg=routed`)
+	if buf.Len() != 0 {
+		t.Errorf("expected no default logger output, got:\n%s", buf.String())
+	}
+	ft.finish()
+}
+
+func TestWrapDefaultLogger(t *testing.T) {
+	for _, before := range []bool{true, false} {
+		var buf bytes.Buffer
+		original := slog.Default()
+		slog.SetDefault(slog.New(slog.HandlerOptions{}.NewTextHandler(&buf)))
+
+		// Wrapping the default logger before or after New, even twice, adds
+		// the attributes from the context once.
+		ft := &fakeT{}
+		if before {
+			slogctx.WrapDefaultLoggerWithCtxHandler()
+		}
+		ctx, _ := slogctxtest.New(ft)
+		slogctx.WrapDefaultLoggerWithCtxHandler()
+		slogctx.WrapDefaultLoggerWithCtxHandler()
+
+		slogctx.Info(slogctx.WithAttrs(ctx, "a", 1), "routed")
+		slogctx.Info(slogctx.WithAttrs(context.Background(), "b", 2), "unrouted")
+		ft.check(t, `slogctxtest_test.go:\d+: level=INFO msg=routed a=1`)
+		if got := buf.String(); !strings.HasSuffix(got, " level=INFO msg=unrouted b=2\n") || strings.Count(got, "\n") != 1 {
+			t.Errorf("before=%v: expected the unrouted record once with b=2 once, got:\n%s", before, got)
+		}
+
+		ft.finish()
+		slog.SetDefault(original)
+	}
+}
+
+func TestRestoreDefault(t *testing.T) {
+	original := slog.Default()
+	t.Run("New", func(t *testing.T) {
+		slogctxtest.New(t)
+		if slog.Default() == original {
+			t.Error("expected New to install a router on the default logger")
+		}
+	})
+	if slog.Default() != original {
+		t.Error("expected the default logger to be restored after the test")
+	}
+}
+
+// Output returns a writer calling Log for each line, without a trailing
+// newline.
+func (t *fakeT) Output() io.Writer {
+	return fakeOutput{t}
+}
+
+type fakeOutput struct {
+	t *fakeT
+}
+
+func (o fakeOutput) Write(p []byte) (int, error) {
+	o.t.Log(strings.TrimSuffix(string(p), "\n"))
+	return len(p), nil
+}
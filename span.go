@@ -0,0 +1,70 @@
+package slogctx
+
+import (
+	"context"
+
+	"golang.org/x/exp/slog"
+)
+
+// Keys used by the ctxHandler for spans created with Start.
+const (
+	// SpanIDKey is the key for the ID of the current span. The associated
+	// value is a string.
+	SpanIDKey = "spanID"
+	// ParentSpanIDKey is the key for the ID of the parent of the current
+	// span. The associated value is a string. It is omitted for root spans.
+	ParentSpanIDKey = "parentSpanID"
+	// DurationKey is the key for the duration of a span logged when the span
+	// ends. The associated value is a time.Duration.
+	DurationKey = "duration"
+)
+
+// Start starts a span named name and logs that it started.
+//
+// The returned context carries a new span ID from the IDGenerator set with
+// WithIDGenerator, and the ID of the span in ctx as parent span ID. All logs
+// using the returned context include both as attributes, so nested spans form
+// a tree that can be reconstructed from the log output.
+//
+// The returned function ends the span and logs its duration, measured with the
+// Clock set with WithClock, at LevelInfo, or at LevelError with err if err is
+// non-nil. It should be called exactly once. The args are included in both the
+// start and end logs. Usage:
+//
+//	ctx, end := logger.Start(ctx, "fetch", "url", url)
+//	defer func() { end(err) }()
+//
+// Requires a slog.Handler wrapped with WrapWithCtxHandler.
+func (l *Logger) Start(ctx context.Context, name string, args ...any) (context.Context, func(err error)) {
+	return l.start(2, ctx, name, args)
+}
+
+// Start calls Logger.Start on the default logger.
+func Start(ctx context.Context, name string, args ...any) (context.Context, func(err error)) {
+	return Default().start(2, ctx, name, args)
+}
+
+// start implements Start. calldepth is passed to LogDepth for the start log.
+func (l *Logger) start(calldepth int, ctx context.Context, name string, args []any) (context.Context, func(err error)) {
+	newInfo := cloneInfo(ctx)
+	newInfo.parentSpanID = newInfo.spanID
+	newInfo.spanID = idGeneratorFrom(ctx).NewID()
+	ctx = context.WithValue(ctx, ctxKey{}, &newInfo)
+
+	clock := clockFrom(ctx)
+	start := clock.Now()
+	l.Inner.WithContext(ctx).LogDepth(calldepth, slog.LevelInfo, name+" started", args...)
+
+	end := func(err error) {
+		endArgs := make([]any, 0, len(args)+2)
+		endArgs = append(endArgs, args...)
+		endArgs = append(endArgs, slog.Duration(DurationKey, clock.Now().Sub(start)))
+		if err != nil {
+			endArgs = append(endArgs, slog.Any(slog.ErrorKey, err))
+			l.Inner.WithContext(ctx).LogDepth(1, slog.LevelError, name+" failed", endArgs...)
+			return
+		}
+		l.Inner.WithContext(ctx).LogDepth(1, slog.LevelInfo, name+" finished", endArgs...)
+	}
+	return ctx, end
+}
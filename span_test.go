@@ -0,0 +1,75 @@
+package slogctx_test
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/jellevandenhooff/slogctx"
+	"github.com/jellevandenhooff/slogctx/slogctxtest"
+	"golang.org/x/exp/slog"
+)
+
+func TestStart(t *testing.T) {
+	check := setupTestSlogHandler(t, slog.HandlerOptions{AddSource: true})
+
+	// setup slogctx
+	slogctx.WrapDefaultLoggerWithCtxHandler()
+
+	ctx := context.Background()
+	ctx = slogctx.WithAttrs(ctx, "hi", "there")
+
+	outerCtx, endOuter := slogctx.Start(ctx, "outer", "arg", 1)
+	check(`level=INFO source=.*/span_test.go:.* msg="outer started" arg=1 hi=there spanID=[0-9a-f]{16}`)
+
+	slogctx.Info(outerCtx, "inside")
+	check(`level=INFO source=.*/span_test.go:.* msg=inside hi=there spanID=[0-9a-f]{16}`)
+
+	innerCtx, endInner := slogctx.Default().Start(outerCtx, "inner")
+	check(`level=INFO source=.*/span_test.go:.* msg="inner started" hi=there spanID=[0-9a-f]{16} parentSpanID=[0-9a-f]{16}`)
+
+	endInner(os.ErrClosed)
+	check(`level=ERROR source=.*/span_test.go:.* msg="inner failed" duration=.* err="file already closed" hi=there spanID=[0-9a-f]{16} parentSpanID=[0-9a-f]{16}`)
+
+	slogctx.Info(innerCtx, "after")
+	check(`level=INFO source=.*/span_test.go:.* msg=after hi=there spanID=[0-9a-f]{16} parentSpanID=[0-9a-f]{16}`)
+
+	endOuter(nil)
+	check(`level=INFO source=.*/span_test.go:.* msg="outer finished" arg=1 duration=.* hi=there spanID=[0-9a-f]{16}`)
+
+	slogctx.Info(ctx, "outside")
+	check(`level=INFO source=.*/span_test.go:.* msg=outside hi=there`)
+}
+
+func TestStartTree(t *testing.T) {
+	rec := slogctxtest.NewRecorder()
+	logger := rec.Logger()
+
+	ctx := context.Background()
+	rootCtx, endRoot := logger.Start(ctx, "root")
+	childCtx, endChild := logger.Start(rootCtx, "child")
+	logger.Info(childCtx, "work")
+	endChild(nil)
+	endRoot(nil)
+
+	rootID := rec.RequireLogged(t, slog.LevelInfo, "root started").ContextAttrs[slogctx.SpanIDKey].String()
+	childID := rec.RequireLogged(t, slog.LevelInfo, "child started").ContextAttrs[slogctx.SpanIDKey].String()
+	if rootID == childID {
+		t.Errorf("expected distinct span IDs, got %s twice", rootID)
+	}
+
+	root := rec.WithContextAttrs(slogctx.SpanIDKey, rootID)
+	root.RequireLogged(t, slog.LevelInfo, "root finished")
+	for _, r := range root.Records() {
+		if _, ok := r.ContextAttrs[slogctx.ParentSpanIDKey]; ok {
+			t.Errorf("expected root span without parent, got %s", r)
+		}
+	}
+
+	child := rec.WithContextAttrs(slogctx.SpanIDKey, childID, slogctx.ParentSpanIDKey, rootID)
+	child.RequireLogged(t, slog.LevelInfo, "work")
+	child.RequireLogged(t, slog.LevelInfo, "child finished")
+	if got := len(child.Records()); got != 3 {
+		t.Errorf("expected 3 logs in child span, got %d", got)
+	}
+}
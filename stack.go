@@ -0,0 +1,118 @@
+package slogctx
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"strings"
+)
+
+// StackKey is the key used by the ctxHandler for the stack of the log call.
+// The associated value is a Stack.
+const StackKey = "stack"
+
+// DefaultStackDepth is the maximum number of frames in a stack if
+// CtxHandlerOptions.StackDepth is zero.
+const DefaultStackDepth = 32
+
+// StackFrame is a single frame in a Stack.
+type StackFrame struct {
+	Function string `json:"function"`
+	File     string `json:"file"`
+	Line     int    `json:"line"`
+}
+
+// Stack is a call stack captured by the ctxHandler, starting at the log
+// statement. It marshals to a JSON array of frames.
+type Stack []StackFrame
+
+// String formats the stack as a single line for text output.
+func (s Stack) String() string {
+	var b strings.Builder
+	for i, f := range s {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		fmt.Fprintf(&b, "%s (%s:%d)", f.Function, f.File, f.Line)
+	}
+	return b.String()
+}
+
+// DefaultStackFilter excludes frames from the runtime, slog, and slogctx
+// packages.
+func DefaultStackFilter(frame runtime.Frame) bool {
+	return !strings.HasPrefix(frame.Function, "runtime.") && !isLoggingFrame(frame)
+}
+
+// isLoggingFrame reports whether frame is in the slog or slogctx package.
+func isLoggingFrame(frame runtime.Frame) bool {
+	return strings.HasPrefix(frame.Function, "golang.org/x/exp/slog.") ||
+		strings.HasPrefix(frame.Function, "github.com/jellevandenhooff/slogctx.")
+}
+
+// WithStackTrace requests a stack for all log calls using this context,
+// regardless of their level.
+//
+// Requires a slog.Handler wrapped with WrapWithCtxHandler.
+func WithStackTrace(ctx context.Context) context.Context {
+	newInfo := cloneInfo(ctx)
+	newInfo.stack = true
+	return context.WithValue(ctx, ctxKey{}, &newInfo)
+}
+
+// captureStack returns the current stack starting at the frame with program
+// counter pc or, if pc is zero or not on the stack, for example for records
+// created with slog.NewRecord, at the first frame outside the slog and slogctx
+// packages. Only frames accepted by the filter are included.
+func (h *ctxHandler) captureStack(pc uintptr) Stack {
+	depth := h.opts.StackDepth
+	if depth == 0 {
+		depth = DefaultStackDepth
+	}
+	filter := h.opts.StackFilter
+	if filter == nil {
+		filter = DefaultStackFilter
+	}
+
+	pcs := make([]uintptr, 64)
+	for {
+		n := runtime.Callers(2, pcs)
+		if n < len(pcs) {
+			pcs = pcs[:n]
+			break
+		}
+		pcs = make([]uintptr, 2*len(pcs))
+	}
+	skip := true
+	for i, p := range pcs {
+		if p == pc {
+			pcs = pcs[i:]
+			skip = false
+			break
+		}
+	}
+
+	var stack Stack
+	frames := runtime.CallersFrames(pcs)
+	for len(stack) < depth {
+		frame, more := frames.Next()
+		if skip && isLoggingFrame(frame) {
+			if !more {
+				break
+			}
+			continue
+		}
+		skip = false
+		if filter(frame) {
+			stack = append(stack, StackFrame{
+				Function: frame.Function,
+				File:     frame.File,
+				Line:     frame.Line,
+			})
+		}
+		if !more {
+			break
+		}
+	}
+	return stack
+}
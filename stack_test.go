@@ -0,0 +1,141 @@
+package slogctx_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jellevandenhooff/slogctx"
+	"golang.org/x/exp/slog"
+)
+
+type stackRecord struct {
+	Msg   string               `json:"msg"`
+	Stack []slogctx.StackFrame `json:"stack"`
+}
+
+func setupStackLogger(t *testing.T, opts slogctx.CtxHandlerOptions) (*slogctx.Logger, func() []stackRecord) {
+	var buf bytes.Buffer
+	logger := slogctx.NewLogger(slog.New(opts.Wrap(slog.HandlerOptions{}.NewJSONHandler(&buf))))
+
+	read := func() []stackRecord {
+		t.Helper()
+		var records []stackRecord
+		dec := json.NewDecoder(&buf)
+		for dec.More() {
+			var r stackRecord
+			if err := dec.Decode(&r); err != nil {
+				t.Fatal(err)
+			}
+			records = append(records, r)
+		}
+		buf.Reset()
+		return records
+	}
+	return logger, read
+}
+
+func TestStackLevel(t *testing.T) {
+	logger, read := setupStackLogger(t, slogctx.CtxHandlerOptions{
+		StackLevel: slog.LevelError,
+	})
+	ctx := context.Background()
+
+	logger.Info(ctx, "no stack")
+	logger.Error(ctx, "stack", os.ErrClosed)
+
+	records := read()
+	if len(records) != 2 {
+		t.Fatalf("expected 2 records, got %d", len(records))
+	}
+	if records[0].Stack != nil {
+		t.Errorf("expected no stack for INFO, got %v", records[0].Stack)
+	}
+	stack := records[1].Stack
+	if len(stack) == 0 {
+		t.Fatal("expected stack for ERROR")
+	}
+	if !strings.HasSuffix(stack[0].Function, ".TestStackLevel") {
+		t.Errorf("expected stack to start at TestStackLevel, got %s", stack[0].Function)
+	}
+	if !strings.HasSuffix(stack[0].File, "stack_test.go") {
+		t.Errorf("expected stack to start in stack_test.go, got %s", stack[0].File)
+	}
+	for _, frame := range stack {
+		if strings.HasPrefix(frame.Function, "runtime.") {
+			t.Errorf("expected runtime frames to be filtered, got %s", frame.Function)
+		}
+	}
+}
+
+func TestStackDepthAndFilter(t *testing.T) {
+	logger, read := setupStackLogger(t, slogctx.CtxHandlerOptions{
+		StackLevel: slog.LevelError,
+		StackDepth: 1,
+		StackFilter: func(frame runtime.Frame) bool {
+			return !strings.HasSuffix(frame.Function, ".TestStackDepthAndFilter")
+		},
+	})
+
+	logger.Error(context.Background(), "stack", nil)
+
+	records := read()
+	if len(records) != 1 || len(records[0].Stack) != 1 {
+		t.Fatalf("expected 1 record with 1 frame, got %v", records)
+	}
+	if got := records[0].Stack[0].Function; got != "testing.tRunner" {
+		t.Errorf("expected filtered stack to start at testing.tRunner, got %s", got)
+	}
+}
+
+func TestStackWithoutPC(t *testing.T) {
+	var buf bytes.Buffer
+	h := slogctx.CtxHandlerOptions{
+		StackLevel:  slog.LevelError,
+		StackFilter: func(runtime.Frame) bool { return true },
+	}.Wrap(slog.HandlerOptions{}.NewJSONHandler(&buf))
+
+	// A record without a PC gets a stack starting at the first frame
+	// outside slog and slogctx, even with a filter accepting all frames.
+	r := slog.NewRecord(time.Now(), slog.LevelError, "stack", 0, context.Background())
+	if err := h.Handle(r); err != nil {
+		t.Fatal(err)
+	}
+
+	var record stackRecord
+	if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+		t.Fatal(err)
+	}
+	if len(record.Stack) == 0 {
+		t.Fatal("expected stack")
+	}
+	if got := record.Stack[0].Function; !strings.HasSuffix(got, ".TestStackWithoutPC") {
+		t.Errorf("expected stack to start at TestStackWithoutPC, got %s", got)
+	}
+}
+
+func TestWithStackTrace(t *testing.T) {
+	logger, read := setupStackLogger(t, slogctx.CtxHandlerOptions{})
+	ctx := context.Background()
+
+	logger.Error(ctx, "no stack", nil)
+
+	stackCtx := slogctx.WithAttrs(slogctx.WithStackTrace(ctx), "hello", "world")
+	logger.Info(stackCtx, "stack")
+
+	records := read()
+	if len(records) != 2 {
+		t.Fatalf("expected 2 records, got %d", len(records))
+	}
+	if records[0].Stack != nil {
+		t.Errorf("expected no stack without options, got %v", records[0].Stack)
+	}
+	if len(records[1].Stack) == 0 || !strings.HasSuffix(records[1].Stack[0].Function, ".TestWithStackTrace") {
+		t.Errorf("expected stack starting at TestWithStackTrace, got %v", records[1].Stack)
+	}
+}
@@ -0,0 +1,84 @@
+package slogctx
+
+import (
+	"context"
+	"runtime"
+	"strings"
+
+	"golang.org/x/exp/slog"
+)
+
+// TraceDepthKey is the key used by TraceCall for the nesting depth of the
+// traced function. The associated value is an int.
+const TraceDepthKey = "depth"
+
+// TraceCall logs the entry of the calling function at LevelTrace, and returns a
+// function that logs its exit with the elapsed time. The args are included in
+// the entry log. Usage:
+//
+//	func process(ctx context.Context, id int) {
+//		defer slogctx.TraceCall(ctx, "id", id)()
+//		...
+//	}
+//
+// TraceCall only logs if the default logger is enabled at LevelTrace for ctx, for
+// example using WithMinimumLevel. Messages are indented by the nesting depth
+// of ctx; use StartTraceCall to trace functions calling other traced
+// functions.
+//
+// TraceCall was previously named Trace. That name now belongs to the top-level
+// function logging at LevelTrace, like Debug and Info; replace
+// defer slogctx.Trace(ctx)() with defer slogctx.TraceCall(ctx)().
+func TraceCall(ctx context.Context, args ...any) func() {
+	_, exit := traceCall(ctx, args)
+	return exit
+}
+
+// StartTraceCall is like TraceCall, but also returns a context one level
+// deeper than ctx, so that functions traced with it are indented below the
+// calling function. Usage:
+//
+//	func process(ctx context.Context, id int) {
+//		ctx, exit := slogctx.StartTraceCall(ctx, "id", id)
+//		defer exit()
+//		fetch(ctx, id)
+//	}
+//
+// The depth is stored in the returned context, so goroutines tracing calls
+// with contexts derived from the same context do not affect each other.
+func StartTraceCall(ctx context.Context, args ...any) (context.Context, func()) {
+	return traceCall(ctx, args)
+}
+
+// traceCall implements TraceCall and StartTraceCall, which must call it
+// directly.
+func traceCall(ctx context.Context, args []any) (context.Context, func()) {
+	logger := slog.Default().WithContext(ctx)
+	if !logger.Enabled(LevelTrace) {
+		return ctx, func() {}
+	}
+
+	var pcs [1]uintptr
+	runtime.Callers(3, pcs[:])
+	frame, _ := runtime.CallersFrames(pcs[:]).Next()
+	name := frame.Function
+	if i := strings.LastIndexByte(name, '/'); i >= 0 {
+		name = name[i+1:]
+	}
+
+	newInfo := cloneInfo(ctx)
+	depth := newInfo.traceDepth
+	newInfo.traceDepth++
+	indent := strings.Repeat("  ", depth)
+
+	enterArgs := make([]any, 0, len(args)+1)
+	enterArgs = append(enterArgs, args...)
+	enterArgs = append(enterArgs, slog.Int(TraceDepthKey, depth))
+	logger.LogDepth(2, LevelTrace, indent+"enter "+name, enterArgs...)
+
+	clock := clockFrom(ctx)
+	start := clock.Now()
+	return context.WithValue(ctx, ctxKey{}, &newInfo), func() {
+		logger.LogDepth(1, LevelTrace, indent+"exit "+name, slog.Duration(DurationKey, clock.Now().Sub(start)), slog.Int(TraceDepthKey, depth))
+	}
+}
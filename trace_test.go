@@ -0,0 +1,57 @@
+package slogctx_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/jellevandenhooff/slogctx"
+	"golang.org/x/exp/slog"
+)
+
+func traceInner(ctx context.Context) {
+	defer slogctx.TraceCall(ctx)()
+}
+
+func traceOuter(ctx context.Context, check func(string)) {
+	ctx, exit := slogctx.StartTraceCall(ctx, "arg", 1)
+	defer exit()
+	check(`level=DEBUG-4 source=.*/trace_test.go:16 msg="enter slogctx_test.traceOuter" arg=1 depth=0`)
+
+	traceInner(ctx)
+	check(`level=DEBUG-4 source=.*/trace_test.go:12 msg="  enter slogctx_test.traceInner" depth=1~` +
+		`time=` + timeRE + ` level=DEBUG-4 source=.*/trace_test.go:13 msg="  exit slogctx_test.traceInner" duration=.* depth=1`)
+}
+
+func TestTrace(t *testing.T) {
+	check := setupTestSlogHandler(t, slog.HandlerOptions{AddSource: true})
+
+	// setup slogctx
+	slogctx.WrapDefaultLoggerWithCtxHandler()
+
+	ctx := context.Background()
+
+	traceInner(ctx)
+	check(``)
+
+	ctx = slogctx.WithMinimumLevel(ctx, slogctx.LevelTrace)
+	traceInner(ctx)
+	check(`level=DEBUG-4 source=.*/trace_test.go:12 msg="enter slogctx_test.traceInner" depth=0~` +
+		`time=` + timeRE + ` level=DEBUG-4 source=.*/trace_test.go:13 msg="exit slogctx_test.traceInner" duration=.* depth=0`)
+
+	traceOuter(ctx, check)
+	check(`level=DEBUG-4 source=.*/trace_test.go:23 msg="exit slogctx_test.traceOuter" duration=.* depth=0`)
+
+	// Calls using the same context are at the same depth.
+	_, exit1 := slogctx.StartTraceCall(ctx)
+	_, exit2 := slogctx.StartTraceCall(ctx)
+	exit2()
+	exit1()
+	check(`level=DEBUG-4 source=.* msg="enter slogctx_test.TestTrace" depth=0~` +
+		`time=` + timeRE + ` level=DEBUG-4 source=.* msg="enter slogctx_test.TestTrace" depth=0~` +
+		`time=` + timeRE + ` level=DEBUG-4 source=.* msg="exit slogctx_test.TestTrace" duration=.* depth=0~` +
+		`time=` + timeRE + ` level=DEBUG-4 source=.* msg="exit slogctx_test.TestTrace" duration=.* depth=0`)
+
+	ctx = slogctx.WithMinimumLevel(ctx, slog.LevelDebug)
+	traceOuter(ctx, func(string) {})
+	check(``)
+}
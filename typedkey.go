@@ -0,0 +1,96 @@
+package slogctx
+
+import (
+	"context"
+
+	"golang.org/x/exp/slog"
+)
+
+// Key is a typed key for a value stored in a context and logged as an
+// attribute.
+//
+// Unlike attributes added with WithAttrs, the value can be read back with Get,
+// so that the value the program uses and the value in the logs come from a
+// single source. Usage:
+//
+//	var userID = slogctx.NewKey[string]("userID")
+//
+//	ctx = userID.Set(ctx, id)
+//	slogctx.Info(ctx, "authorized") // logs userID=...
+//	id, ok := userID.Get(ctx)
+type Key[T any] struct {
+	name string
+}
+
+// NewKey returns a new Key whose values are logged with the attribute key
+// name. Keys are distinct even if they have the same name.
+func NewKey[T any](name string) *Key[T] {
+	return &Key[T]{name: name}
+}
+
+// Name returns the attribute key of k.
+func (k *Key[T]) Name() string {
+	return k.name
+}
+
+// Set returns a context with the value of k set to v. Records logged with the
+// context include the attribute (k.Name(), v), like attributes added with
+// WithAttrs. If k is already set in ctx, v replaces the previous value, keeping
+// its position among the attributes.
+//
+// Logging the attribute requires a slog.Handler wrapped with
+// WrapWithCtxHandler; Get works with any handler.
+func (k *Key[T]) Set(ctx context.Context, v T) context.Context {
+	return setKey(ctx, k, slog.Any(k.name, v), v)
+}
+
+// Get returns the value of k set in ctx with Set, and whether k is set.
+func (k *Key[T]) Get(ctx context.Context) (T, bool) {
+	v, ok := getKey(ctx, k)
+	if !ok {
+		var zero T
+		return zero, false
+	}
+	t, _ := v.(T) // v is a nil interface if T is an interface type
+	return t, true
+}
+
+// setKey returns a context with the value of key set to value, logged as
+// attr.
+func setKey(ctx context.Context, key any, attr slog.Attr, value any) context.Context {
+	info, _ := ctx.Value(ctxKey{}).(*ctxInfo)
+	if _, ok := getKey(ctx, key); ok {
+		return context.WithValue(ctx, ctxKey{}, info.replaceKey(key, attr, value))
+	}
+	newInfo := info.appendSegment([]slog.Attr{attr})
+	newInfo.key = key
+	newInfo.keyValue = value
+	return context.WithValue(ctx, ctxKey{}, &newInfo)
+}
+
+// getKey returns the value of key in the chain of the ctxInfo stored in ctx.
+func getKey(ctx context.Context, key any) (any, bool) {
+	info, _ := ctx.Value(ctxKey{}).(*ctxInfo)
+	for ; info != nil; info = info.parent {
+		if info.key == key {
+			return info.keyValue, true
+		}
+	}
+	return nil, false
+}
+
+// replaceKey returns a copy of the chain ending at info with the segment of
+// key, which must be in the chain, replaced by attr and value. The copies
+// share all other segments, and their caches, with the chain.
+func (info *ctxInfo) replaceKey(key any, attr slog.Attr, value any) *ctxInfo {
+	newInfo := *info
+	if info.key == key {
+		newInfo.attrs = []slog.Attr{attr}
+		newInfo.keyValue = value
+		newInfo.cache = newSegmentCache(newInfo.attrs)
+	} else {
+		newInfo.parent = info.parent.replaceKey(key, attr, value)
+	}
+	newInfo.flat = newFlatAttrs(newInfo.depth)
+	return &newInfo
+}
@@ -0,0 +1,76 @@
+package slogctx_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/jellevandenhooff/slogctx"
+	"golang.org/x/exp/slog"
+)
+
+func TestKey(t *testing.T) {
+	check := setupTestSlogHandler(t, slog.HandlerOptions{})
+	slogctx.WrapDefaultLoggerWithCtxHandler()
+
+	userID := slogctx.NewKey[int]("userID")
+	role := slogctx.NewKey[string]("role")
+	otherUserID := slogctx.NewKey[int]("userID")
+
+	ctx := context.Background()
+	if _, ok := userID.Get(ctx); ok {
+		t.Error("expected userID to be unset")
+	}
+
+	ctx = userID.Set(ctx, 42)
+	ctx = slogctx.WithAttrs(ctx, "requestID", 1)
+	ctx = role.Set(ctx, "admin")
+	ctx = slogctx.WithMinimumLevel(ctx, slog.LevelDebug)
+
+	if id, ok := userID.Get(ctx); !ok || id != 42 {
+		t.Errorf("userID.Get = %d, %v, want 42, true", id, ok)
+	}
+	if r, ok := role.Get(ctx); !ok || r != "admin" {
+		t.Errorf("role.Get = %q, %v, want admin, true", r, ok)
+	}
+	if _, ok := otherUserID.Get(ctx); ok {
+		t.Error("expected key with the same name to be unset")
+	}
+	slogctx.Debug(ctx, "hi")
+	check(`level=DEBUG msg=hi userID=42 requestID=1 role=admin`)
+
+	// Setting a key again replaces its value in place.
+	replaced := userID.Set(ctx, 7)
+	if id, _ := userID.Get(replaced); id != 7 {
+		t.Errorf("userID.Get after Set = %d, want 7", id)
+	}
+	slogctx.Debug(replaced, "replaced")
+	check(`level=DEBUG msg=replaced userID=7 requestID=1 role=admin`)
+
+	slogctx.Debug(ctx, "original")
+	check(`level=DEBUG msg=original userID=42 requestID=1 role=admin`)
+}
+
+func TestKeyInterface(t *testing.T) {
+	errKey := slogctx.NewKey[error]("err")
+	ctx := errKey.Set(context.Background(), nil)
+	if err, ok := errKey.Get(ctx); !ok || err != nil {
+		t.Errorf("errKey.Get = %v, %v, want nil, true", err, ok)
+	}
+}
+
+func TestKeyDeepChain(t *testing.T) {
+	check := setupTestSlogHandler(t, slog.HandlerOptions{})
+	slogctx.WrapDefaultLoggerWithCtxHandler()
+
+	userID := slogctx.NewKey[int]("userID")
+	ctx := userID.Set(context.Background(), 1)
+	for i := 0; i < 6; i++ {
+		ctx = slogctx.WithAttrs(ctx, "i", i)
+	}
+	slogctx.Info(ctx, "deep")
+	check(`level=INFO msg=deep userID=1 i=0 i=1 i=2 i=3 i=4 i=5`)
+
+	ctx = userID.Set(ctx, 2)
+	slogctx.Info(ctx, "replaced")
+	check(`level=INFO msg=replaced userID=2 i=0 i=1 i=2 i=3 i=4 i=5`)
+}